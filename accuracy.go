@@ -0,0 +1,363 @@
+package wappa
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultRegionPrecision is the decimal places of the regions of AccuracyTracker,
+// 1 being cells of about 11 kilometers.
+const DefaultRegionPrecision = 1
+
+// EstimateRecord links the estimate chosen for a ride to its final value.
+type EstimateRecord struct {
+	RideID     int
+	ExternalID string
+	EmployeeID int
+	CategoryID int
+	TypeID     int
+	// Category and subcategory descriptions.
+	Category string
+	// Estimate after the discount of the subcategory. See SubCategory.DiscountedEstimate.
+	Estimate Estimate
+	// Region of the origin of the ride.
+	Region string
+	// When the ride was created.
+	At time.Time
+	// Final value of the ride, set once it is completed or paid.
+	Value  float64
+	Final  bool
+	Source EventSource
+}
+
+// Deviation returns how far the final value is outside of the estimate
+// band, positive above the maximum and negative below the minimum.
+func (r *EstimateRecord) Deviation() float64 {
+	switch {
+	case r.Value > r.Estimate.Maximum:
+		return r.Value - r.Estimate.Maximum
+	case r.Value < r.Estimate.Minimum:
+		return r.Value - r.Estimate.Minimum
+	default:
+		return 0
+	}
+}
+
+// Error returns the final value relative to the middle of the estimate band,
+// i.e. 0.1 for a value 10% above it.
+func (r *EstimateRecord) Error() float64 {
+	mid := (r.Estimate.Minimum + r.Estimate.Maximum) / 2
+	if mid == 0 {
+		return 0
+	}
+	return (r.Value - mid) / mid
+}
+
+// EstimateStore keeps the records of an AccuracyTracker.
+type EstimateStore interface {
+	// Put stores the record, replacing the one of the same ride.
+	Put(ctx context.Context, r *EstimateRecord) error
+	// Get returns the record of the ride ID, or of the external ID if
+	// the ID is 0 or unknown. It returns nil if there is none.
+	Get(ctx context.Context, rideID int, externalID string) (*EstimateRecord, error)
+	// List returns all the records.
+	List(ctx context.Context) ([]*EstimateRecord, error)
+}
+
+// MemoryEstimateStore is an in-memory EstimateStore. It is safe for concurrent use.
+type MemoryEstimateStore struct {
+	mu         sync.Mutex
+	records    map[int]*EstimateRecord
+	byExternal map[string]int
+}
+
+// NewMemoryEstimateStore returns an empty MemoryEstimateStore.
+func NewMemoryEstimateStore() *MemoryEstimateStore {
+	return &MemoryEstimateStore{
+		records:    make(map[int]*EstimateRecord),
+		byExternal: make(map[string]int),
+	}
+}
+
+// Put implements the EstimateStore interface.
+func (s *MemoryEstimateStore) Put(ctx context.Context, r *EstimateRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp := *r
+	s.records[r.RideID] = &cp
+	if r.ExternalID != "" {
+		s.byExternal[r.ExternalID] = r.RideID
+	}
+
+	return nil
+}
+
+// Get implements the EstimateStore interface.
+func (s *MemoryEstimateStore) Get(ctx context.Context, rideID int, externalID string) (*EstimateRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.records[rideID]
+	if !ok && externalID != "" {
+		if id, found := s.byExternal[externalID]; found {
+			r, ok = s.records[id]
+		}
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	cp := *r
+	return &cp, nil
+}
+
+// List implements the EstimateStore interface.
+func (s *MemoryEstimateStore) List(ctx context.Context) ([]*EstimateRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]*EstimateRecord, 0, len(s.records))
+	for _, r := range s.records {
+		cp := *r
+		res = append(res, &cp)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].RideID < res[j].RideID
+	})
+
+	return res, nil
+}
+
+// RegionFunc returns the region of a coordinate.
+type RegionFunc func(lat, lng float64) string
+
+// GridRegion returns a RegionFunc of cells of the
+// coordinates rounded to prec decimal places.
+func GridRegion(prec int) RegionFunc {
+	return func(lat, lng float64) string {
+		return strconv.FormatFloat(lat, 'f', prec, 64) + "," + strconv.FormatFloat(lng, 'f', prec, 64)
+	}
+}
+
+// AccuracyTracker tracks how accurate the estimates of the rides created
+// are, comparing them to the final values of the rides. It is safe for
+// concurrent use, and implements RideHandler to be fed by webhooks.
+type AccuracyTracker struct {
+	// Store of the records.
+	Store EstimateStore
+	// Region of the rides by their origin. GridRegion(DefaultRegionPrecision) is used if nil.
+	Region RegionFunc
+	// Time zone of the hours of the report. time.Local is used if nil.
+	TimeZone *time.Location
+	// Fraction of the estimate band a value may be outside of
+	// it before it is out of band, i.e. 0.05 for 5%.
+	Tolerance float64
+	// OnOutOfBand is called with the rides whose final value is out of band.
+	OnOutOfBand func(r *EstimateRecord)
+
+	mu sync.Mutex
+}
+
+// NewAccuracyTracker returns an AccuracyTracker keeping its records in the store.
+func NewAccuracyTracker(store EstimateStore) *AccuracyTracker {
+	return &AccuracyTracker{Store: store}
+}
+
+// Record records the estimate of the quote chosen for the ride created, by its
+// TaxiCategoryID, if set, and TaxiTypeID. It returns ErrNoQuoteSelected if the quote has
+// no such subcategory.
+func (t *AccuracyTracker) Record(ctx context.Context, ride *Ride, res *RideResult, q *QuoteResult) error {
+	var c *Category
+	var s *SubCategory
+	for _, cat := range q.Categories {
+		if ride.TaxiCategoryID != 0 && cat.ID != ride.TaxiCategoryID {
+			continue
+		}
+		for i := range cat.SubCategories {
+			if cat.SubCategories[i].TypeID == ride.TaxiTypeID {
+				c, s = cat, &cat.SubCategories[i]
+				break
+			}
+		}
+		if s != nil {
+			break
+		}
+	}
+	if s == nil {
+		return ErrNoQuoteSelected
+	}
+
+	region := t.Region
+	if region == nil {
+		region = GridRegion(DefaultRegionPrecision)
+	}
+
+	externalID := ride.ExternalID
+	if externalID == "" {
+		externalID = res.Info.ExternalID
+	}
+
+	return t.Store.Put(ctx, &EstimateRecord{
+		RideID:     res.ID,
+		ExternalID: externalID,
+		EmployeeID: ride.EmployeeID,
+		CategoryID: c.ID,
+		TypeID:     s.TypeID,
+		Category:   c.Description + " " + s.Description,
+		Estimate:   s.DiscountedEstimate(),
+		Region:     region(ride.LatOrigin, ride.LngOrigin),
+		At:         time.Now(),
+	})
+}
+
+// ObserveRide captures the final value of the ride, i.e. the result of RideService.Read.
+func (t *AccuracyTracker) ObserveRide(ctx context.Context, r *RideResult) error {
+	return t.observe(ctx, SourcePoll, r.ID, r.Info.ExternalID, r.Info.Status, r.Info.RideValue)
+}
+
+// HandleRide implements the RideHandler interface, capturing the final value of the ride.
+func (t *AccuracyTracker) HandleRide(ctx context.Context, r *WebhookRide) error {
+	return t.observe(ctx, SourceWebhook, r.RideID, r.ExternalID, r.Status, r.RideValue)
+}
+
+// ObserveHistory captures the final values of the rides of EmployeeService.LastRides.
+func (t *AccuracyTracker) ObserveHistory(ctx context.Context, res *EmployeeLastRidesResult) error {
+	for _, h := range res.History {
+		var externalID string
+		if h.Info.ExternalID != 0 {
+			externalID = strconv.Itoa(h.Info.ExternalID)
+		}
+
+		if err := t.observe(ctx, SourcePoll, h.ID, externalID, h.Info.Status, h.Info.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// observe sets the final value of the recorded ride, calling OnOutOfBand if it
+// changed and is out of band. Rides not recorded or not finished are ignored.
+func (t *AccuracyTracker) observe(ctx context.Context, source EventSource, rideID int, externalID string, status RideStatus, value float64) error {
+	if (status != RideStatusCompleted && status != RideStatusPaid) || value <= 0 {
+		return nil
+	}
+
+	t.mu.Lock()
+	r, err := t.Store.Get(ctx, rideID, externalID)
+	if err != nil || r == nil || (r.Final && r.Value == value) {
+		t.mu.Unlock()
+		return err
+	}
+
+	r.Value, r.Final, r.Source = value, true, source
+	err = t.Store.Put(ctx, r)
+	t.mu.Unlock()
+
+	if err == nil && t.OnOutOfBand != nil && t.outOfBand(r) {
+		t.OnOutOfBand(r)
+	}
+	return err
+}
+
+func (t *AccuracyTracker) outOfBand(r *EstimateRecord) bool {
+	margin := (r.Estimate.Maximum - r.Estimate.Minimum) * t.Tolerance
+	return math.Abs(r.Deviation()) > margin
+}
+
+// AccuracyStats are the deviations of the final values of rides from their estimates.
+type AccuracyStats struct {
+	// Rides with a final value.
+	Count     int
+	OutOfBand int
+	// Mean and largest deviation from the estimate band. See EstimateRecord.Deviation.
+	MeanDeviation float64
+	MaxDeviation  float64
+	// Mean error relative to the middle of the band. See EstimateRecord.Error.
+	MeanError float64
+}
+
+// InBandRate returns the fraction of the rides within the estimate band.
+func (s AccuracyStats) InBandRate() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return float64(s.Count-s.OutOfBand) / float64(s.Count)
+}
+
+func (s *AccuracyStats) add(r *EstimateRecord, outOfBand bool) {
+	s.Count++
+	if outOfBand {
+		s.OutOfBand++
+	}
+
+	n := float64(s.Count)
+	dev := r.Deviation()
+	s.MeanDeviation += (dev - s.MeanDeviation) / n
+	s.MeanError += (r.Error() - s.MeanError) / n
+	if math.Abs(dev) > math.Abs(s.MaxDeviation) {
+		s.MaxDeviation = dev
+	}
+}
+
+// AccuracyReport is the accuracy of the estimates overall and by category,
+// region and hour of the day the rides were created.
+type AccuracyReport struct {
+	Overall    AccuracyStats
+	ByCategory map[string]*AccuracyStats
+	ByRegion   map[string]*AccuracyStats
+	ByHour     map[int]*AccuracyStats
+	// Rides recorded without a final value yet.
+	Pending int
+}
+
+// Report returns the accuracy of the estimates of the rides with a final value.
+func (t *AccuracyTracker) Report(ctx context.Context) (*AccuracyReport, error) {
+	records, err := t.Store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tz := t.TimeZone
+	if tz == nil {
+		tz = time.Local
+	}
+
+	rep := &AccuracyReport{
+		ByCategory: make(map[string]*AccuracyStats),
+		ByRegion:   make(map[string]*AccuracyStats),
+		ByHour:     make(map[int]*AccuracyStats),
+	}
+
+	for _, r := range records {
+		if !r.Final {
+			rep.Pending++
+			continue
+		}
+
+		out := t.outOfBand(r)
+		rep.Overall.add(r, out)
+		statsOf(rep.ByCategory, r.Category).add(r, out)
+		statsOf(rep.ByRegion, r.Region).add(r, out)
+
+		hour := r.At.In(tz).Hour()
+		if rep.ByHour[hour] == nil {
+			rep.ByHour[hour] = &AccuracyStats{}
+		}
+		rep.ByHour[hour].add(r, out)
+	}
+
+	return rep, nil
+}
+
+func statsOf(m map[string]*AccuracyStats, key string) *AccuracyStats {
+	if m[key] == nil {
+		m[key] = &AccuracyStats{}
+	}
+	return m[key]
+}
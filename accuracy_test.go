@@ -0,0 +1,154 @@
+package wappa
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEstimateRecord(t *testing.T) {
+	testCases := []struct {
+		value         float64
+		wantDeviation float64
+		wantError     float64
+	}{
+		{25, 0, 0.25},
+		{33, 3, 0.65},
+		{8, -2, -0.6},
+	}
+
+	for _, tc := range testCases {
+		r := &EstimateRecord{Estimate: Estimate{Minimum: 10, Maximum: 30}, Value: tc.value}
+		if got := r.Deviation(); got != tc.wantDeviation {
+			t.Errorf("got deviation of %g: %g; want %g.", tc.value, got, tc.wantDeviation)
+		}
+		if got := r.Error(); got < tc.wantError-1e-9 || got > tc.wantError+1e-9 {
+			t.Errorf("got error of %g: %g; want %g.", tc.value, got, tc.wantError)
+		}
+	}
+}
+
+func TestAccuracyTracker(t *testing.T) {
+	ctx := context.Background()
+	q := testQuote(time.Now())
+	q.Categories[0].SubCategories[0].Estimate.Minimum = 20
+
+	var outOfBand []int
+	tr := NewAccuracyTracker(NewMemoryEstimateStore())
+	tr.Tolerance = 0.1
+	tr.OnOutOfBand = func(r *EstimateRecord) { outOfBand = append(outOfBand, r.RideID) }
+
+	rides := []struct {
+		id         int
+		externalID string
+		typeID     int
+		lat        float64
+	}{
+		{1, "a", 10, -23.55},
+		{2, "b", 10, -23.56},
+		{3, "3", 20, -22.9},
+		{4, "d", 11, -23.55},
+	}
+	for _, r := range rides {
+		ride := &Ride{EmployeeID: 7, TaxiTypeID: r.typeID, LatOrigin: r.lat, LngOrigin: -46.63, ExternalID: r.externalID}
+		if err := tr.Record(ctx, ride, &RideResult{ID: r.id}, q); err != nil {
+			t.Fatalf("got error calling Record(): %s; want nil.", err.Error())
+		}
+	}
+
+	// Within the band, from Ride.Read.
+	tr.ObserveRide(ctx, &RideResult{ID: 1, Info: RideInfo{Status: RideStatusCompleted, RideValue: 25}})
+	// Above the band by the external ID, from a webhook, with an earlier status ignored.
+	tr.HandleRide(ctx, &WebhookRide{Status: RideStatusInProgress, ExternalID: "b", RideValue: 10})
+	tr.HandleRide(ctx, &WebhookRide{Status: RideStatusPaid, ExternalID: "b", RideValue: 40})
+	// Within the tolerance, from the history, twice.
+	history := &EmployeeLastRidesResult{History: []*RideHistory{
+		{Info: HistoricalRideInfo{Status: "ride-paid", ExternalID: 3, Value: 26}},
+		{ID: 99, Info: HistoricalRideInfo{Status: "ride-paid", Value: 50}},
+	}}
+	tr.ObserveHistory(ctx, history)
+	tr.ObserveHistory(ctx, history)
+
+	if len(outOfBand) != 1 || outOfBand[0] != 2 {
+		t.Errorf("got out of band rides: %v; want [2].", outOfBand)
+	}
+
+	rep, err := tr.Report(ctx)
+	if err != nil {
+		t.Fatalf("got error calling Report(): %s; want nil.", err.Error())
+	}
+
+	if o := rep.Overall; o.Count != 3 || o.OutOfBand != 1 || o.MaxDeviation != 10 || rep.Pending != 1 {
+		t.Errorf("got report: %+v, %d pending; want 3 rides, 1 out of band by 10 and 1 pending.", o, rep.Pending)
+	}
+
+	if s := rep.ByCategory["Taxi Comum"]; s == nil || s.Count != 2 || s.InBandRate() != 0.5 {
+		t.Errorf("got Taxi Comum stats: %+v; want 2 rides, half in band.", s)
+	}
+
+	if s := rep.ByRegion["-23.6,-46.6"]; s == nil || s.Count != 2 {
+		t.Errorf("got regions: %v; want 2 rides in -23.6,-46.6.", rep.ByRegion)
+	}
+
+	if s := rep.ByHour[time.Now().Hour()]; s == nil || s.Count != 3 {
+		t.Errorf("got hours: %v; want 3 rides in the current hour.", rep.ByHour)
+	}
+}
+
+func TestAccuracyTrackerRecordCategory(t *testing.T) {
+	store := NewMemoryEstimateStore()
+	tr := NewAccuracyTracker(store)
+
+	// Both categories have a subcategory of the same type.
+	q := testQuote(time.Now())
+	q.Categories[1].SubCategories[0].TypeID = 10
+
+	if err := tr.Record(context.Background(), &Ride{TaxiTypeID: 10, TaxiCategoryID: 2}, &RideResult{ID: 1}, q); err != nil {
+		t.Fatalf("got error calling Record(): %s; want nil.", err.Error())
+	}
+
+	if r, _ := store.Get(context.Background(), 1, ""); r == nil || r.CategoryID != 2 || r.Category != "Pop Pop" {
+		t.Errorf("got record: %+v; want the subcategory of category 2.", r)
+	}
+}
+
+func TestAccuracyTrackerRecordDiscount(t *testing.T) {
+	store := NewMemoryEstimateStore()
+	tr := NewAccuracyTracker(store)
+
+	q := testQuote(time.Now())
+	q.Categories[0].SubCategories[1].Estimate.Minimum = 35
+	q.Categories[0].SubCategories[1].Discount = 10
+
+	if err := tr.Record(context.Background(), &Ride{TaxiTypeID: 11}, &RideResult{ID: 1}, q); err != nil {
+		t.Fatalf("got error calling Record(): %s; want nil.", err.Error())
+	}
+
+	// The value charged is compared to the band offered to the employee.
+	opt := QuoteOptions(q)[1]
+	if r, _ := store.Get(context.Background(), 1, ""); r == nil || r.Estimate.Minimum != opt.MinPrice || r.Estimate.Maximum != opt.MaxPrice {
+		t.Errorf("got record: %+v; want the estimate of %g to %g after the discount.", r, opt.MinPrice, opt.MaxPrice)
+	}
+}
+
+func TestAccuracyTrackerRecordError(t *testing.T) {
+	tr := NewAccuracyTracker(NewMemoryEstimateStore())
+	q := testQuote(time.Now())
+
+	testCases := []struct {
+		name string
+		ride *Ride
+	}{
+		{"unknown type", &Ride{TaxiTypeID: 99}},
+		{"other category", &Ride{TaxiTypeID: 10, TaxiCategoryID: 2}},
+		{"unknown category", &Ride{TaxiTypeID: 10, TaxiCategoryID: 3}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tr.Record(context.Background(), tc.ride, &RideResult{ID: 1}, q); err != ErrNoQuoteSelected {
+				t.Errorf("got error: %v; want %v.", err, ErrNoQuoteSelected)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package wappa
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Default maximum age of the quotes used by RideBuilder.
+const DefaultMaxQuoteAge = 5 * time.Minute
+
+// Errors of RideBuilder.
+var (
+	ErrQuoteExpired    = errors.New("wappa: quote expired")
+	ErrNoQuoteSelected = errors.New("wappa: no quote subcategory selected")
+)
+
+// QuoteSelector picks a subcategory from a quote, returning
+// it and its category, or nil if none fits.
+type QuoteSelector func(q *QuoteResult) (*Category, *SubCategory)
+
+// selectBest returns the subcategory with the lowest key, the first on ties.
+func selectBest(q *QuoteResult, key func(s *SubCategory) float64) (*Category, *SubCategory) {
+	var bestCat *Category
+	var best *SubCategory
+	for _, c := range q.Categories {
+		for i := range c.SubCategories {
+			s := &c.SubCategories[i]
+			if best == nil || key(s) < key(best) {
+				bestCat, best = c, s
+			}
+		}
+	}
+	return bestCat, best
+}
+
+// selectFirst returns the first subcategory matching.
+func selectFirst(q *QuoteResult, match func(s *SubCategory) bool) (*Category, *SubCategory) {
+	for _, c := range q.Categories {
+		for i := range c.SubCategories {
+			if s := &c.SubCategories[i]; match(s) {
+				return c, s
+			}
+		}
+	}
+	return nil, nil
+}
+
+// CheapestQuote selects the subcategory with the lowest maximum price.
+func CheapestQuote(q *QuoteResult) (*Category, *SubCategory) {
+	return selectBest(q, func(s *SubCategory) float64 {
+		return s.Estimate.Maximum
+	})
+}
+
+// FastestPickup selects the subcategory with the shortest time to pickup.
+func FastestPickup(q *QuoteResult) (*Category, *SubCategory) {
+	return selectBest(q, func(s *SubCategory) float64 {
+		return float64(s.Estimate.TimeToPickup.Duration)
+	})
+}
+
+// DefaultQuote selects the subcategory flagged as default.
+func DefaultQuote(q *QuoteResult) (*Category, *SubCategory) {
+	return selectFirst(q, func(s *SubCategory) bool {
+		return s.Default
+	})
+}
+
+// QuoteByDescription returns a QuoteSelector of the subcategory
+// with the description, compared case-insensitively.
+func QuoteByDescription(desc string) QuoteSelector {
+	return func(q *QuoteResult) (*Category, *SubCategory) {
+		return selectFirst(q, func(s *SubCategory) bool {
+			return strings.EqualFold(strings.TrimSpace(s.Description), strings.TrimSpace(desc))
+		})
+	}
+}
+
+// RideBuilder builds the rides of the subcategories selected from quotes.
+type RideBuilder struct {
+	// Ride is the template of the rides built, with the same
+	// employee and coordinates of the quotes.
+	Ride Ride
+	// Maximum age of the quotes, by their EstimatedAt. Quotes without it are
+	// refused. DefaultMaxQuoteAge is used if zero, and no age is checked if negative.
+	MaxQuoteAge time.Duration
+}
+
+// NewRideBuilder returns a RideBuilder of rides of the employee
+// between the locations the quotes were estimated for.
+func NewRideBuilder(employeeID int, origin, destiny Location) *RideBuilder {
+	return &RideBuilder{
+		Ride: Ride{
+			EmployeeID: employeeID,
+			LatOrigin:  origin.Lat,
+			LngOrigin:  origin.Lng,
+			LatDestiny: destiny.Lat,
+			LngDestiny: destiny.Lng,
+		},
+	}
+}
+
+// Build returns the ride of the subcategory selected from the quote, validated.
+// It returns ErrQuoteExpired if the quote is older than MaxQuoteAge, and
+// ErrNoQuoteSelected if the selector picks no subcategory.
+func (b *RideBuilder) Build(q *QuoteResult, sel QuoteSelector) (*Ride, error) {
+	maxAge := b.MaxQuoteAge
+	if maxAge == 0 {
+		maxAge = DefaultMaxQuoteAge
+	}
+	if maxAge > 0 && (q.EstimatedAt == nil || time.Since(*q.EstimatedAt) > maxAge) {
+		return nil, ErrQuoteExpired
+	}
+
+	c, s := sel(q)
+	if s == nil {
+		return nil, ErrNoQuoteSelected
+	}
+
+	r := b.Ride
+	r.TaxiTypeID = s.TypeID
+	r.TaxiCategoryID = c.ID
+
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
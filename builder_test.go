@@ -0,0 +1,93 @@
+package wappa
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// testQuote returns a quote estimated at the given time.
+func testQuote(at time.Time) *QuoteResult {
+	return &QuoteResult{
+		Categories: []*Category{
+			{
+				ID:          1,
+				Description: "Taxi",
+				SubCategories: []SubCategory{
+					{TypeID: 10, Description: "Comum", Default: true, Estimate: Estimate{Maximum: 30, TimeToPickup: DurationSec{5 * time.Minute}}},
+					{TypeID: 11, Description: "Executivo", Estimate: Estimate{Maximum: 45, TimeToPickup: DurationSec{2 * time.Minute}}},
+				},
+			},
+			{
+				ID:          2,
+				Description: "Pop",
+				SubCategories: []SubCategory{
+					{TypeID: 20, Description: "Pop", Estimate: Estimate{Maximum: 25, TimeToPickup: DurationSec{8 * time.Minute}}},
+				},
+			},
+		},
+		EstimatedAt: &at,
+	}
+}
+
+func TestRideBuilder(t *testing.T) {
+	testCases := []struct {
+		name         string
+		sel          QuoteSelector
+		wantType     int
+		wantCategory int
+	}{
+		{"cheapest", CheapestQuote, 20, 2},
+		{"fastest", FastestPickup, 11, 1},
+		{"default", DefaultQuote, 10, 1},
+		{"description", QuoteByDescription("executivo "), 11, 1},
+	}
+
+	b := NewRideBuilder(7, Location{-23.5505, -46.6333}, Location{-23.5874, -46.6576})
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := b.Build(testQuote(time.Now()), tc.sel)
+			if err != nil {
+				t.Fatalf("got error calling Build(): %s; want nil.", err.Error())
+			}
+
+			if r.TaxiTypeID != tc.wantType || r.TaxiCategoryID != tc.wantCategory {
+				t.Errorf("got type %d and category %d; want %d and %d.", r.TaxiTypeID, r.TaxiCategoryID, tc.wantType, tc.wantCategory)
+			}
+
+			if r.EmployeeID != 7 || r.LatOrigin != -23.5505 || r.LngDestiny != -46.6576 {
+				t.Errorf("got ride: %+v; want the employee and coordinates of the builder.", r)
+			}
+		})
+	}
+}
+
+func TestRideBuilderError(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+	undated := testQuote(time.Now())
+	undated.EstimatedAt = nil
+
+	testCases := []struct {
+		name    string
+		builder *RideBuilder
+		quote   *QuoteResult
+		sel     QuoteSelector
+		wantErr error
+	}{
+		{"expired", NewRideBuilder(7, Location{1, 1}, Location{2, 2}), testQuote(old), DefaultQuote, ErrQuoteExpired},
+		{"undated", NewRideBuilder(7, Location{1, 1}, Location{2, 2}), undated, DefaultQuote, ErrQuoteExpired},
+		{"age unchecked", &RideBuilder{Ride: Ride{EmployeeID: 7, LatOrigin: 1, LngOrigin: 1, LatDestiny: 2, LngDestiny: 2}, MaxQuoteAge: -1}, undated, DefaultQuote, nil},
+		{"not selected", NewRideBuilder(7, Location{1, 1}, Location{2, 2}), testQuote(time.Now()), QuoteByDescription("Moto"), ErrNoQuoteSelected},
+		{"invalid ride", NewRideBuilder(0, Location{1, 1}, Location{2, 2}), testQuote(time.Now()), DefaultQuote, ErrValidation},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.builder.Build(tc.quote, tc.sel)
+			if !errors.Is(err, tc.wantErr) || (tc.wantErr == nil && err != nil) {
+				t.Errorf("got error: %v; want %v.", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package wappa
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// TokenCache stores tokens between uses of a RefreshingTokenSource,
+// so a valid token can be reused until its expiration.
+type TokenCache interface {
+	// Get returns the token cached for the key, or nil if there is none.
+	Get(key string) (*oauth2.Token, error)
+	// Put caches the token for the key.
+	Put(key string, tk *oauth2.Token) error
+}
+
+// TokenCacheKey returns the key of the tokens of an user in the API host.
+func TokenCacheKey(host, username string) string {
+	return host + "|" + username
+}
+
+// MemoryTokenCache is a TokenCache that keeps the tokens in memory.
+// It is safe for concurrent use.
+type MemoryTokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]*oauth2.Token
+}
+
+// NewMemoryTokenCache returns an empty MemoryTokenCache.
+func NewMemoryTokenCache() *MemoryTokenCache {
+	return &MemoryTokenCache{tokens: make(map[string]*oauth2.Token)}
+}
+
+// Get implements the TokenCache interface.
+func (c *MemoryTokenCache) Get(key string) (*oauth2.Token, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.tokens[key], nil
+}
+
+// Put implements the TokenCache interface.
+func (c *MemoryTokenCache) Put(key string, tk *oauth2.Token) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tokens[key] = tk
+	return nil
+}
+
+// FileTokenCache is a TokenCache that keeps each token in a file of a directory,
+// readable only by its owner. Files are replaced atomically.
+type FileTokenCache struct {
+	dir string
+}
+
+// NewFileTokenCache returns a FileTokenCache storing the tokens in dir.
+// The directory is created on the first Put if it doesn't exist.
+func NewFileTokenCache(dir string) *FileTokenCache {
+	return &FileTokenCache{dir: dir}
+}
+
+// path returns the path of the file of the key. The key is hashed
+// so it can be safely used as a file name.
+func (c *FileTokenCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Get implements the TokenCache interface.
+func (c *FileTokenCache) Get(key string) (*oauth2.Token, error) {
+	b, err := ioutil.ReadFile(c.path(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tk := &oauth2.Token{}
+	if err := json.Unmarshal(b, tk); err != nil {
+		return nil, err
+	}
+
+	return tk, nil
+}
+
+// Put implements the TokenCache interface.
+func (c *FileTokenCache) Put(key string, tk *oauth2.Token) error {
+	b, err := json.Marshal(tk)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+
+	// TempFile creates the file with 0600 permission.
+	f, err := ioutil.TempFile(c.dir, ".token-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), c.path(key))
+}
@@ -0,0 +1,170 @@
+package wappa
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"golang.org/x/oauth2"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "wappa-cache")
+	if err != nil {
+		t.Fatalf("got error creating temp dir: %s; want nil.", err.Error())
+	}
+	return dir
+}
+
+func TestFileTokenCache(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	c := NewFileTokenCache(filepath.Join(dir, "tokens"))
+	key := TokenCacheKey("http://host/", "user")
+
+	tk, err := c.Get(key)
+	if err != nil || tk != nil {
+		t.Fatalf("got Get() of missing key: %v, %v; want nil, nil.", tk, err)
+	}
+
+	want := &oauth2.Token{AccessToken: "token", Expiry: time.Now().Add(time.Hour).Round(time.Second)}
+	if err := c.Put(key, want); err != nil {
+		t.Fatalf("got error calling Put(): %s; want nil.", err.Error())
+	}
+
+	fi, err := os.Stat(c.path(key))
+	if err != nil {
+		t.Fatalf("got error calling os.Stat(): %s; want nil.", err.Error())
+	}
+
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Errorf("got file permission: %o; want 600.", perm)
+	}
+
+	tk, err = c.Get(key)
+	if err != nil {
+		t.Fatalf("got error calling Get(): %s; want nil.", err.Error())
+	}
+
+	if tk.AccessToken != want.AccessToken || !tk.Expiry.Equal(want.Expiry) {
+		t.Errorf("got token: %+v; want %+v.", tk, want)
+	}
+
+	if err := ioutil.WriteFile(c.path(key), []byte("{"), 0600); err != nil {
+		t.Fatalf("got error writing file: %s; want nil.", err.Error())
+	}
+
+	if _, err := c.Get(key); err == nil {
+		t.Errorf("got error nil reading corrupt entry; want not nil.")
+	}
+}
+
+func TestMemoryTokenCache(t *testing.T) {
+	c := NewMemoryTokenCache()
+
+	if tk, _ := c.Get("key"); tk != nil {
+		t.Errorf("got token %+v for missing key; want nil.", tk)
+	}
+
+	want := &oauth2.Token{AccessToken: "token"}
+	c.Put("key", want)
+
+	if tk, _ := c.Get("key"); tk != want {
+		t.Errorf("got token %+v; want %+v.", tk, want)
+	}
+}
+
+func TestRefreshingTokenSourceCache(t *testing.T) {
+	valid, _ := (&testTokenSource{exp: time.Hour}).Token()
+	expired, _ := (&testTokenSource{exp: -time.Hour}).Token()
+
+	testCases := []struct {
+		name      string
+		cached    *oauth2.Token
+		corrupt   bool
+		wantFetch int32
+	}{
+		{"empty", nil, false, 1},
+		{"valid", valid, false, 0},
+		{"expired", expired, false, 1},
+		{"corrupt", nil, true, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := tempDir(t)
+			defer os.RemoveAll(dir)
+
+			cache := NewFileTokenCache(dir)
+			if tc.cached != nil {
+				cache.Put("key", tc.cached)
+			}
+			if tc.corrupt {
+				ioutil.WriteFile(cache.path("key"), []byte("corrupt"), 0600)
+			}
+
+			src := &testTokenSource{exp: time.Hour}
+			ts := NewRefreshingTokenSource(src, &RefreshOptions{Cache: cache, CacheKey: "key"})
+
+			tk, err := ts.Token()
+			if err != nil {
+				t.Fatalf("got error calling Token(): %s; want nil.", err.Error())
+			}
+
+			if got := atomic.LoadInt32(&src.calls); got != tc.wantFetch {
+				t.Errorf("got %d fetches; want %d.", got, tc.wantFetch)
+			}
+
+			cached, err := cache.Get("key")
+			if err != nil || cached.AccessToken != tk.AccessToken {
+				t.Errorf("got cached token: %+v, %v; want %+v.", cached, err, tk)
+			}
+		})
+	}
+}
+
+func TestRefreshingTokenSourceCacheVerified(t *testing.T) {
+	sign := func(key string, claims *jwt.StandardClaims) *oauth2.Token {
+		ss, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(key))
+		return &oauth2.Token{AccessToken: ss, Expiry: time.Unix(claims.ExpiresAt, 0)}
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	testCases := []struct {
+		name     string
+		cached   *oauth2.Token
+		wantUsed bool
+	}{
+		{"verified", sign("AllYourBase", &jwt.StandardClaims{ExpiresAt: exp}), true},
+		{"tampered", sign("wrong", &jwt.StandardClaims{ExpiresAt: exp}), false},
+		{"not yet valid", sign("AllYourBase", &jwt.StandardClaims{ExpiresAt: exp, NotBefore: exp}), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := tokenServer(time.Hour)
+			defer s.Close()
+
+			src := NewTokenSource(context.Background(), s.URL+"/", "", "")
+			src.VerifyKey = []byte("AllYourBase")
+
+			cache := NewMemoryTokenCache()
+			cache.Put("key", tc.cached)
+
+			tk, err := NewRefreshingTokenSource(src, &RefreshOptions{Cache: cache, CacheKey: "key"}).Token()
+			if err != nil {
+				t.Fatalf("got error calling Token(): %s; want nil.", err.Error())
+			}
+
+			if used := tk.AccessToken == tc.cached.AccessToken; used != tc.wantUsed {
+				t.Errorf("got cached token used %t; want %t.", used, tc.wantUsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package wappa
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Names of the claims holding the roles of the user.
+var roleClaims = []string{"role", "roles", "http://schemas.microsoft.com/ws/2008/06/identity/claims/role"}
+
+// Names of the claims holding the company of the user.
+var companyClaims = []string{"company", "companyId", "company_id"}
+
+// Claims is a typed view of the claims of a Wappa JWT.
+type Claims struct {
+	Subject   string
+	Issuer    string
+	Company   string
+	Roles     []string
+	Scopes    []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	NotBefore time.Time
+
+	// All the claims of the token.
+	Raw jwt.MapClaims
+}
+
+// ParseClaims returns the claims of a JWT without verifying its signature.
+func ParseClaims(accessToken string) (*Claims, error) {
+	return parseClaims(accessToken, nil)
+}
+
+// Errors of the claims validated by Claims.Validate instead of the JWT parser.
+const timeValidationErrors = jwt.ValidationErrorExpired | jwt.ValidationErrorIssuedAt | jwt.ValidationErrorNotValidYet
+
+// parseClaims returns the claims of a JWT, verifying its signature if key is not nil.
+func parseClaims(accessToken string, key interface{}) (*Claims, error) {
+	raw := jwt.MapClaims{}
+
+	var err error
+	if key == nil {
+		_, _, err = new(jwt.Parser).ParseUnverified(accessToken, raw)
+	} else {
+		_, err = jwt.ParseWithClaims(accessToken, raw, verifyKeyFunc(key))
+
+		var ve *jwt.ValidationError
+		if errors.As(err, &ve) && ve.Errors&^timeValidationErrors == 0 {
+			err = nil
+		}
+	}
+
+	if err != nil {
+		// The token is left out, as the error may be logged.
+		return nil, fmt.Errorf("invalid JWT token: %w", err)
+	}
+
+	return newClaims(raw), nil
+}
+
+// verifyKeyFunc returns a jwt.Keyfunc accepting only the signing methods of the key type.
+func verifyKeyFunc(key interface{}) jwt.Keyfunc {
+	return func(t *jwt.Token) (interface{}, error) {
+		var ok bool
+		switch key.(type) {
+		case *rsa.PublicKey:
+			switch t.Method.(type) {
+			case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+				ok = true
+			}
+		case *ecdsa.PublicKey:
+			_, ok = t.Method.(*jwt.SigningMethodECDSA)
+		case []byte:
+			_, ok = t.Method.(*jwt.SigningMethodHMAC)
+		}
+
+		if !ok {
+			return nil, fmt.Errorf("unexpected signing method: %s.", t.Header["alg"])
+		}
+		return key, nil
+	}
+}
+
+func newClaims(raw jwt.MapClaims) *Claims {
+	c := &Claims{
+		Subject:   claimString(raw["sub"]),
+		Issuer:    claimString(raw["iss"]),
+		IssuedAt:  claimTime(raw["iat"]),
+		ExpiresAt: claimTime(raw["exp"]),
+		NotBefore: claimTime(raw["nbf"]),
+		Raw:       raw,
+	}
+
+	for _, name := range companyClaims {
+		if v, ok := raw[name]; ok {
+			c.Company = claimString(v)
+			break
+		}
+	}
+
+	for _, name := range roleClaims {
+		c.Roles = append(c.Roles, claimStrings(raw[name])...)
+	}
+
+	for _, name := range []string{"scope", "scp"} {
+		for _, s := range claimStrings(raw[name]) {
+			c.Scopes = append(c.Scopes, strings.Fields(s)...)
+		}
+	}
+
+	return c
+}
+
+// Validate checks that the token is already valid at the given time, according
+// to its not-before and issued-at claims, allowing for the clock skew.
+// The expiration is not checked, as expired tokens are refreshed by the token sources.
+func (c *Claims) Validate(now time.Time, skew time.Duration) error {
+	if !c.NotBefore.IsZero() && now.Add(skew).Before(c.NotBefore) {
+		return fmt.Errorf("token not valid before %s.", c.NotBefore)
+	}
+
+	if !c.IssuedAt.IsZero() && now.Add(skew).Before(c.IssuedAt) {
+		return fmt.Errorf("token issued in the future at %s.", c.IssuedAt)
+	}
+
+	return nil
+}
+
+// HasRole reports if the token has the given role.
+func (c *Claims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func claimString(v interface{}) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case float64:
+		return fmt.Sprintf("%.0f", s)
+	default:
+		return fmt.Sprint(s)
+	}
+}
+
+func claimStrings(v interface{}) []string {
+	switch s := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		ss := make([]string, 0, len(s))
+		for _, e := range s {
+			ss = append(ss, claimString(e))
+		}
+		return ss
+	default:
+		return []string{claimString(s)}
+	}
+}
+
+func claimTime(v interface{}) time.Time {
+	if f, ok := v.(float64); ok {
+		return time.Unix(int64(f), 0)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,122 @@
+package wappa
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestParseClaims(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	raw := jwt.MapClaims{
+		"sub":       "user@company.com",
+		"iss":       "wappa",
+		"companyId": float64(123),
+		"role":      []interface{}{"admin", "dispatcher"},
+		"scope":     "rides webhooks",
+		"iat":       float64(now.Unix()),
+		"nbf":       float64(now.Unix()),
+		"exp":       float64(now.Add(time.Hour).Unix()),
+	}
+	ss, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, raw).SignedString([]byte("secret"))
+
+	got, err := ParseClaims(ss)
+	if err != nil {
+		t.Fatalf("got error calling ParseClaims(): %s; want nil.", err.Error())
+	}
+
+	want := &Claims{
+		Subject:   "user@company.com",
+		Issuer:    "wappa",
+		Company:   "123",
+		Roles:     []string{"admin", "dispatcher"},
+		Scopes:    []string{"rides", "webhooks"},
+		IssuedAt:  now,
+		ExpiresAt: now.Add(time.Hour),
+		NotBefore: now,
+		Raw:       raw,
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got claims: %+v; want %+v.", got, want)
+	}
+
+	if !got.HasRole("admin") || got.HasRole("driver") {
+		t.Errorf("got HasRole() not matching roles %v.", got.Roles)
+	}
+
+	if _, err := ParseClaims("invalid"); err == nil {
+		t.Errorf("got error nil parsing invalid token; want not nil.")
+	}
+}
+
+func TestClaimsValidate(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		claims  *Claims
+		skew    time.Duration
+		wantErr bool
+	}{
+		{&Claims{}, 0, false},
+		{&Claims{IssuedAt: now.Add(-time.Minute), NotBefore: now.Add(-time.Minute)}, 0, false},
+		{&Claims{NotBefore: now.Add(time.Minute)}, 0, true},
+		{&Claims{NotBefore: now.Add(time.Minute)}, 2 * time.Minute, false},
+		{&Claims{IssuedAt: now.Add(time.Minute)}, 0, true},
+		{&Claims{IssuedAt: now.Add(time.Minute)}, 2 * time.Minute, false},
+		{&Claims{ExpiresAt: now.Add(-time.Minute)}, 0, false},
+	}
+
+	for _, tc := range testCases {
+		if err := tc.claims.Validate(now, tc.skew); (err != nil) != tc.wantErr {
+			t.Errorf("got Validate(%+v, %s): %v; want error %t.", tc.claims, tc.skew, err, tc.wantErr)
+		}
+	}
+}
+
+func TestParseClaimsVerified(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("got error generating RSA key: %s; want nil.", err.Error())
+	}
+
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	// Expired tokens are still parsed, as expiration is handled by the token sources.
+	claims := &jwt.StandardClaims{Subject: "user", ExpiresAt: time.Now().Add(-time.Hour).Unix()}
+	signed, _ := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+	hmac, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+
+	testCases := []struct {
+		token   string
+		key     interface{}
+		wantErr bool
+	}{
+		{signed, &key.PublicKey, false},
+		{signed, &other.PublicKey, true},
+		{hmac, &key.PublicKey, true},
+		{hmac, []byte("secret"), false},
+		{hmac, []byte("wrong"), true},
+	}
+
+	for _, tc := range testCases {
+		c, err := parseClaims(tc.token, tc.key)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("got error from parseClaims(): %v; want error %t.", err, tc.wantErr)
+		}
+
+		if err != nil && strings.Contains(err.Error(), tc.token) {
+			t.Errorf("got token in the error from parseClaims(): %s; want it left out.", err.Error())
+		}
+
+		if err == nil && c.Subject != "user" {
+			t.Errorf("got Claims.Subject: %s; want 'user'.", c.Subject)
+		}
+	}
+}
@@ -9,6 +9,9 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"time"
+
+	"golang.org/x/oauth2"
 )
 
 // requester is the interface that performs a request
@@ -49,17 +52,6 @@ type Result struct {
 	Message string
 }
 
-// ApiError implements the error interface
-// and returns infos from the request.
-type ApiError struct {
-	statusCode int
-	msg        string
-}
-
-func (e *ApiError) Error() string {
-	return fmt.Sprintf("Error Status Code: %d; Message: %s.", e.statusCode, e.msg)
-}
-
 type service struct {
 	client requester
 }
@@ -73,6 +65,37 @@ type Client struct {
 	// host should always be specified with a trailing slash.
 	host *url.URL
 
+	// Sent in the User-Agent header, if set.
+	userAgent string
+
+	// Default timeout of requests made with a context without deadline.
+	timeout time.Duration
+
+	// Headers sent in every request.
+	header http.Header
+
+	// Authenticates the requests, if set.
+	tokenSource oauth2.TokenSource
+
+	// Cache of the tokens of clients created with credentials.
+	tokenCache TokenCache
+
+	// Key verifying the tokens of clients created with credentials.
+	tokenVerifyKey interface{}
+
+	// Token source of clients created with credentials.
+	credentials *RefreshingTokenSource
+
+	// Limits the rate of requests, if set.
+	limiter *RateLimiter
+
+	// Chain of RoundTrippers the requests go through.
+	middleware []Middleware
+
+	// Retry defines how failed requests are retried.
+	// Requests are not retried if nil.
+	Retry *RetryPolicy
+
 	// reuse a single struct intead of allocation one for each service on the heap.
 	common service
 
@@ -84,12 +107,21 @@ type Client struct {
 	Webhook  *WebhookService
 }
 
-// NewClient returns a new Wappa API client with provided host URL and HTTP client.
-func NewClient(host *url.URL, client *http.Client) *Client {
+// NewClient returns a new Wappa API client with provided host URL and HTTP client,
+// configured by the given options.
+func NewClient(host *url.URL, client *http.Client, opts ...ClientOption) *Client {
 	if client == nil {
 		client = http.DefaultClient
 	}
-	c := &Client{client: client, host: host}
+	// Copies the default policy, so changing the one of a client doesn't change the others.
+	retry := *DefaultRetryPolicy
+	c := &Client{host: host, header: http.Header{}, Retry: &retry}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.client = c.transport(client)
 
 	c.common.client = c
 	// Sets services.
@@ -104,43 +136,113 @@ func NewClient(host *url.URL, client *http.Client) *Client {
 
 // Request created an API request. A relative path can be providaded
 // in which case it is resolved relative to the host of the Client.
+// Responses with a non-2xx status code or reporting Success as false
+// are returned as an *ApiError.
 func (c *Client) Request(ctx context.Context, method string, path endpoint, body, output interface{}) error {
 	u, err := c.host.Parse(path.String())
 	if err != nil {
 		return err
 	}
 
-	var b io.ReadWriter
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	var payload []byte
 	if body != nil {
-		b = new(bytes.Buffer)
+		b := new(bytes.Buffer)
 		if err := json.NewEncoder(b).Encode(body); err != nil {
 			return err
 		}
+		payload = b.Bytes()
 	}
 
-	req, err := http.NewRequest(method, u.String(), b)
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/json")
+	var (
+		res *http.Response
+		bd  []byte
+	)
+	for attempt := 1; ; attempt++ {
+		var b io.Reader
+		if payload != nil {
+			b = bytes.NewReader(payload)
+		}
 
-	req = req.WithContext(ctx)
+		if c.limiter != nil {
+			if _, err := c.limiter.Wait(ctx, limiterKey(path)); err != nil {
+				return err
+			}
+		}
 
-	res, err := c.client.Do(req)
-	if err != nil {
-		return err
+		req, err := c.newRequest(ctx, method, u.String(), b)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		res, err = c.client.Do(req)
+		if err == nil {
+			bd, _ = ioutil.ReadAll(res.Body)
+			res.Body.Close()
+
+			if c.limiter != nil && res.StatusCode == http.StatusTooManyRequests {
+				d, _ := retryAfter(res)
+				c.limiter.throttled(limiterKey(path), d)
+			}
+		}
+
+		wait, retry := c.Retry.next(req, res, err, attempt)
+		if !retry {
+			if err != nil {
+				return err
+			}
+			break
+		}
+
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return checkResponse(res.StatusCode, bd)
 	}
-	defer res.Body.Close()
 
-	bd, _ := ioutil.ReadAll(res.Body)
 	if err := json.Unmarshal(bd, output); err != nil {
 		return &ApiError{
-			statusCode: res.StatusCode,
-			msg:        fmt.Sprintf("Couldn't unmarshal body: '%s'. Message: '%s'.", string(bd), err.Error()),
+			StatusCode: res.StatusCode,
+			Message:    fmt.Sprintf("Couldn't unmarshal body: '%s'. Message: '%s'.", string(bd), err.Error()),
+			Body:       bd,
+			Err:        err,
 		}
 	}
 
-	return nil
+	return checkResponse(res.StatusCode, bd)
+}
+
+// newRequest returns a request with the headers set for the Client.
+func (c *Client) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range c.header {
+		req.Header[k] = append([]string(nil), v...)
+	}
+
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	return req.WithContext(ctx), nil
+}
+
+// withTimeout returns a context with the default timeout
+// of the Client, if ctx has no deadline.
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || c.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.timeout)
 }
 
 // endpoint for checking the API status. Pulled off for teting.
@@ -153,13 +255,14 @@ func (c *Client) Status(ctx context.Context) (ok bool, err error) {
 		return
 	}
 
-	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	req, err := c.newRequest(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
 		return
 	}
 
-	req = req.WithContext(ctx)
-
 	res, err := c.client.Do(req)
 	if err != nil {
 		return
@@ -3,6 +3,7 @@ package wappa
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -329,19 +330,39 @@ func TestClientRequestError(t *testing.T) {
 				w.Write([]byte(http.StatusText(http.StatusInternalServerError)))
 			}),
 			func(e error) {
-				err, ok := e.(*ApiError)
-				if !ok {
+				var err *ApiError
+				if !errors.As(e, &err) {
 					t.Fatal("got error different from *ApiError")
 				}
 
-				if err != nil {
-					if wantStatus := http.StatusInternalServerError; err.statusCode != wantStatus {
-						t.Errorf("got Error.statusCode: %d; want %d.", err.statusCode, wantStatus)
-					}
+				if wantStatus := http.StatusInternalServerError; err.StatusCode != wantStatus {
+					t.Errorf("got Error.StatusCode: %d; want %d.", err.StatusCode, wantStatus)
+				}
+
+				if !errors.Is(err, ErrServer) {
+					t.Errorf("got Error.Err: %v; want %v.", err.Err, ErrServer)
+				}
+
+				if want := http.StatusText(http.StatusInternalServerError); string(err.Body) != want {
+					t.Errorf("got Error.Body: %s; want %s.", err.Body, want)
+				}
+			},
+		},
+		{
+			"",
+			http.MethodGet,
+			nil,
+			newMockServer(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"name": 1}`))
+			}),
+			func(e error) {
+				var err *ApiError
+				if !errors.As(e, &err) {
+					t.Fatal("got error different from *ApiError")
+				}
 
-					if wantSubStr := "invalid character 'I'"; !strings.Contains(err.msg, wantSubStr) {
-						t.Errorf("go Error.msg: %s; want it to contain `%s` substring.", err.msg, wantSubStr)
-					}
+				if wantSubStr := "cannot unmarshal number"; !strings.Contains(err.Message, wantSubStr) {
+					t.Errorf("go Error.Message: %s; want it to contain `%s` substring.", err.Message, wantSubStr)
 				}
 			},
 		},
@@ -0,0 +1,77 @@
+// Command wappasim delivers simulated Wappa webhook payloads to a receiver,
+// replaying the lifecycle of rides.
+//
+// Usage:
+//
+//	wappasim -url http://localhost:8080/webhook -key auth-key -scenario completed
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/mobilitee-smartmob/wappa/v2"
+)
+
+func main() {
+	url := flag.String("url", "", "URL of the webhook receiver (required)")
+	key := flag.String("key", "", "AuthKey of the webhook")
+	header := flag.String("header", wappa.DefaultWebhookAuthHeader, "header carrying the AuthKey")
+	scenario := flag.String("scenario", "completed", "ride lifecycle: completed, cancelled or driver-not-found")
+	rideID := flag.Int("ride", 1, "ID of the first ride")
+	rides := flag.Int("rides", 1, "number of rides")
+	interval := flag.Duration("interval", wappa.DefaultSimulatorInterval, "delay between deliveries")
+	jitter := flag.Duration("jitter", 0, "maximum random delay added to the interval")
+	dup := flag.Float64("duplicate", 0, "probability of delivering a payload twice")
+	reorder := flag.Float64("reorder", 0, "probability of delivering a payload after the next one")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "seed of the random duplicates, reorders and jitter")
+	flag.Parse()
+
+	if *url == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	sc, err := wappa.ParseScenario(*scenario)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	sim := wappa.NewSimulator(*url, *key)
+	sim.AuthHeader = *header
+	sim.Interval = *interval
+	sim.Jitter = *jitter
+	sim.DuplicateRate = *dup
+	sim.ReorderRate = *reorder
+	sim.Rand = rand.New(rand.NewSource(*seed))
+	sim.OnDeliver = func(r *wappa.WebhookRide, status int, err error) {
+		if err != nil {
+			fmt.Printf("ride %d code %d %s: %s\n", r.RideID, r.Code, r.Status, err)
+			return
+		}
+		fmt.Printf("ride %d code %d %s: %d\n", r.RideID, r.Code, r.Status, status)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		cancel()
+	}()
+
+	for id := *rideID; id < *rideID+*rides; id++ {
+		if err := sim.Run(ctx, id, sc); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
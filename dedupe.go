@@ -0,0 +1,178 @@
+package wappa
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Default time a delivery is remembered by a DeliveryFilter.
+const DefaultDedupeWindow = 10 * time.Minute
+
+// DedupeStore keeps the deliveries and ride statuses seen by a DeliveryFilter.
+type DedupeStore interface {
+	// Get returns the value stored for the key and when it was stored.
+	Get(key string) (value string, at time.Time, ok bool)
+	// Put stores the value for the key.
+	Put(key, value string, at time.Time)
+	// Remove deletes the key.
+	Remove(key string)
+}
+
+// Verdict is the result of filtering a webhook delivery.
+type Verdict int
+
+// Verdicts of DeliveryFilter.
+const (
+	// The delivery is new and in order.
+	DeliveryAccepted Verdict = iota
+	// The same delivery was seen within the window.
+	DeliveryDuplicate
+	// The status of the ride goes backwards in its lifecycle,
+	// or leaves a terminal status.
+	DeliveryOutOfOrder
+)
+
+// DeliveryFilter detects duplicated webhook deliveries, identified by their
+// RideID, Status and Code, and deliveries whose status goes backwards in the
+// ride lifecycle, i.e. driver-found after on-ride. It is safe for concurrent use.
+type DeliveryFilter struct {
+	// Store of the deliveries seen.
+	Store DedupeStore
+	// How long a delivery is remembered. DefaultDedupeWindow is used if <= 0.
+	Window time.Duration
+	// DropOutOfOrder drops the out of order deliveries. Otherwise they are handled,
+	// flagged in their context. See OutOfOrder.
+	DropOutOfOrder bool
+	// OnDrop is called with the deliveries dropped and why, if set.
+	OnDrop func(r *WebhookRide, v Verdict)
+
+	mu sync.Mutex
+}
+
+// NewDeliveryFilter returns a DeliveryFilter with an in-memory store of the given capacity.
+func NewDeliveryFilter(capacity int, window time.Duration) *DeliveryFilter {
+	return &DeliveryFilter{Store: NewLRUDedupeStore(capacity), Window: window}
+}
+
+func deliveryKey(r *WebhookRide) string {
+	return fmt.Sprintf("delivery:%d:%s:%d", r.RideID, r.Status, r.Code)
+}
+
+func rideKey(r *WebhookRide) string {
+	return "ride:" + strconv.Itoa(r.RideID)
+}
+
+// Check returns the verdict of the delivery, recording it as seen.
+func (f *DeliveryFilter) Check(r *WebhookRide, now time.Time) Verdict {
+	window := f.Window
+	if window <= 0 {
+		window = DefaultDedupeWindow
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := deliveryKey(r)
+	if _, at, ok := f.Store.Get(key); ok && now.Sub(at) < window {
+		return DeliveryDuplicate
+	}
+	f.Store.Put(key, "", now)
+
+	if !r.Status.Valid() {
+		return DeliveryAccepted
+	}
+
+	if last, _, ok := f.Store.Get(rideKey(r)); ok && outOfOrder(RideStatus(last), r.Status) {
+		return DeliveryOutOfOrder
+	}
+	f.Store.Put(rideKey(r), string(r.Status), now)
+
+	return DeliveryAccepted
+}
+
+// outOfOrder reports if a ride can't move from the last status seen to next,
+// as it comes before it or the last status is terminal.
+func outOfOrder(last, next RideStatus) bool {
+	if last.IsTerminal() {
+		return next != last && !last.CanTransitionTo(next)
+	}
+	return next.Rank() < last.Rank()
+}
+
+// Forget removes the delivery from the ones seen, so it is accepted if delivered again.
+func (f *DeliveryFilter) Forget(r *WebhookRide) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.Store.Remove(deliveryKey(r))
+}
+
+// filter returns if the delivery must be handled, and
+// the context to handle it with.
+func (f *DeliveryFilter) filter(ctx context.Context, r *WebhookRide) (context.Context, bool) {
+	v := f.Check(r, time.Now())
+	switch {
+	case v == DeliveryAccepted:
+		return ctx, true
+	case v == DeliveryOutOfOrder && !f.DropOutOfOrder:
+		return context.WithValue(ctx, outOfOrderKey{}, true), true
+	}
+
+	if f.OnDrop != nil {
+		f.OnDrop(r, v)
+	}
+	return ctx, false
+}
+
+type outOfOrderKey struct{}
+
+// OutOfOrder reports if the ride being handled was delivered out of order.
+func OutOfOrder(ctx context.Context) bool {
+	v, _ := ctx.Value(outOfOrderKey{}).(bool)
+	return v
+}
+
+// LRUDedupeStore is an in-memory DedupeStore keeping up to a number of keys,
+// discarding the least recently used. It is safe for concurrent use.
+type LRUDedupeStore struct {
+	lru *lru
+}
+
+type dedupeEntry struct {
+	value string
+	at    time.Time
+}
+
+// NewLRUDedupeStore returns an LRUDedupeStore keeping up to capacity keys.
+func NewLRUDedupeStore(capacity int) *LRUDedupeStore {
+	return &LRUDedupeStore{lru: newLRU(capacity)}
+}
+
+// Get implements the DedupeStore interface.
+func (s *LRUDedupeStore) Get(key string) (string, time.Time, bool) {
+	v, ok := s.lru.get(key)
+	if !ok {
+		return "", time.Time{}, false
+	}
+
+	entry := v.(dedupeEntry)
+	return entry.value, entry.at, true
+}
+
+// Put implements the DedupeStore interface.
+func (s *LRUDedupeStore) Put(key, value string, at time.Time) {
+	s.lru.put(key, dedupeEntry{value, at})
+}
+
+// Remove implements the DedupeStore interface.
+func (s *LRUDedupeStore) Remove(key string) {
+	s.lru.remove(key)
+}
+
+// Len returns the number of keys in the store.
+func (s *LRUDedupeStore) Len() int {
+	return s.lru.len()
+}
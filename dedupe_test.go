@@ -0,0 +1,120 @@
+package wappa
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeliveryFilterCheck(t *testing.T) {
+	f := NewDeliveryFilter(100, time.Minute)
+	now := time.Now()
+
+	testCases := []struct {
+		ride *WebhookRide
+		at   time.Time
+		want Verdict
+	}{
+		{&WebhookRide{RideID: 1, Status: RideStatusSearchingForDriver, Code: 1}, now, DeliveryAccepted},
+		{&WebhookRide{RideID: 1, Status: RideStatusSearchingForDriver, Code: 1}, now, DeliveryDuplicate},
+		{&WebhookRide{RideID: 1, Status: RideStatusInProgress, Code: 3}, now, DeliveryAccepted},
+		{&WebhookRide{RideID: 1, Status: RideStatusDriverFound, Code: 2}, now, DeliveryOutOfOrder},
+		{&WebhookRide{RideID: 2, Status: RideStatusDriverFound, Code: 2}, now, DeliveryAccepted},
+		{&WebhookRide{RideID: 1, Status: RideStatusInProgress, Code: 4}, now, DeliveryAccepted},
+		{&WebhookRide{RideID: 1, Status: "unknown", Code: 5}, now, DeliveryAccepted},
+		{&WebhookRide{RideID: 1, Status: RideStatusCompleted, Code: 6}, now, DeliveryAccepted},
+		{&WebhookRide{RideID: 1, Status: RideStatusCompleted, Code: 6}, now.Add(2 * time.Minute), DeliveryAccepted},
+		{&WebhookRide{RideID: 1, Status: RideStatusPaid, Code: 7}, now, DeliveryAccepted},
+		{&WebhookRide{RideID: 1, Status: RideStatusPaid, Code: 8}, now, DeliveryAccepted},
+		{&WebhookRide{RideID: 1, Status: RideStatusCancelled, Code: 9}, now, DeliveryOutOfOrder},
+	}
+
+	for _, tc := range testCases {
+		if got := f.Check(tc.ride, tc.at); got != tc.want {
+			t.Errorf("got Check(%+v): %d; want %d.", tc.ride, got, tc.want)
+		}
+	}
+
+	r := &WebhookRide{RideID: 3, Status: RideStatusCancelled}
+	f.Check(r, now)
+	f.Forget(r)
+	if got := f.Check(r, now); got != DeliveryAccepted {
+		t.Errorf("got Check() after Forget(): %d; want %d.", got, DeliveryAccepted)
+	}
+}
+
+func TestLRUDedupeStore(t *testing.T) {
+	s := NewLRUDedupeStore(2)
+	now := time.Now()
+
+	s.Put("a", "1", now)
+	s.Put("b", "2", now)
+	s.Get("a")
+	s.Put("c", "3", now)
+
+	if _, _, ok := s.Get("b"); ok {
+		t.Errorf("got least recently used key 'b'; want it evicted.")
+	}
+
+	if v, at, ok := s.Get("a"); !ok || v != "1" || !at.Equal(now) {
+		t.Errorf("got Get(a): %s, %s, %t; want 1, %s, true.", v, at, ok, now)
+	}
+
+	s.Remove("a")
+	if got := s.Len(); got != 1 {
+		t.Errorf("got Len(): %d; want 1.", got)
+	}
+}
+
+func TestWebhookHandlerFilter(t *testing.T) {
+	testCases := []struct {
+		drop      bool
+		wantCalls int
+		wantFlags int
+		wantDrops int
+	}{
+		{false, 3, 1, 1},
+		{true, 2, 0, 2},
+	}
+
+	for _, tc := range testCases {
+		var calls, flags, drops int
+		h := NewWebhookHandler(&Webhook{AuthKey: "auth-key"}, func(ctx context.Context, r *WebhookRide) error {
+			calls++
+			if OutOfOrder(ctx) {
+				flags++
+			}
+			return nil
+		})
+		h.Filter = NewDeliveryFilter(10, time.Minute)
+		h.Filter.DropOutOfOrder = tc.drop
+		h.Filter.OnDrop = func(r *WebhookRide, v Verdict) {
+			drops++
+		}
+
+		for _, body := range []string{
+			`{"rideId": 1, "code": 1, "status": "on-ride"}`,
+			`{"rideId": 1, "code": 1, "status": "on-ride"}`,
+			`{"rideId": 1, "code": 2, "status": "ride-completed"}`,
+			`{"rideId": 1, "code": 0, "status": "driver-found"}`,
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+			req.Header.Set("Authorization", "auth-key")
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Errorf("got status code: %d; want %d.", rec.Code, http.StatusOK)
+			}
+		}
+
+		if calls != tc.wantCalls || flags != tc.wantFlags || drops != tc.wantDrops {
+			t.Errorf("got %d calls, %d flagged, %d dropped; want %d, %d, %d.",
+				calls, flags, drops, tc.wantCalls, tc.wantFlags, tc.wantDrops)
+		}
+	}
+}
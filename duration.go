@@ -27,6 +27,13 @@ func (d *Duration) UnmarshalJSON(b []byte) (err error) {
 	return nil
 }
 
+// MarshalJSON implements the json.Marshaler interface, formatting
+// the duration as "hh:mm:ss", as the API sends it.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	secs := int64(d.Duration / time.Second)
+	return json.Marshal(fmt.Sprintf("%02d:%02d:%02d", secs/3600, secs/60%60, secs%60))
+}
+
 // DurationSec is a custom seconds duration type for
 // unmashaling data from the API.
 type DurationSec struct {
@@ -29,6 +29,28 @@ func TestDurationUnmarshal(t *testing.T) {
 	}
 }
 
+func TestDurationMarshal(t *testing.T) {
+	testCases := []struct {
+		d    Duration
+		want string
+	}{
+		{Duration{10 * time.Minute}, `"00:10:00"`},
+		{Duration{26*time.Hour + 3*time.Minute + 4*time.Second}, `"26:03:04"`},
+		{Duration{}, `"00:00:00"`},
+	}
+
+	for _, tc := range testCases {
+		b, err := json.Marshal(tc.d)
+		if err != nil {
+			t.Fatalf("got error calling json.Marshal(%s): '%s'; want nil.", tc.d, err.Error())
+		}
+
+		if got := string(b); got != tc.want {
+			t.Errorf("got %s; want %s.", got, tc.want)
+		}
+	}
+}
+
 func TestDurationSecUnmarshal(t *testing.T) {
 	testCases := []struct {
 		payload []byte
@@ -85,7 +85,7 @@ type RideHistory struct {
 // HistoricalRideInfo represents a historical information
 // about a ride made by the employee
 type HistoricalRideInfo struct {
-	Status string `json:"status"`
+	Status RideStatus `json:"status"`
 	StartedAt *Time `json:"rideDate"`
 	EndedAt *Time `json:"finishDate"`
 	PaidAt *Time `json:"paymentDate"`
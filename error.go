@@ -0,0 +1,93 @@
+package wappa
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Kinds of errors returned by the API. They can be checked
+// against an *ApiError with errors.Is.
+var (
+	ErrAuth        = errors.New("wappa: authentication failed")
+	ErrNotFound    = errors.New("wappa: resource not found")
+	ErrValidation  = errors.New("wappa: request validation failed")
+	ErrRateLimited = errors.New("wappa: rate limited")
+	ErrServer      = errors.New("wappa: server error")
+)
+
+// ApiError implements the error interface
+// and returns infos from the request.
+type ApiError struct {
+	// HTTP status code of the response.
+	StatusCode int
+	// Code is the ResponseError.Code returned by the API, if any.
+	Code int
+	// Message returned by the API or describing the failure.
+	Message string
+	// Raw response body.
+	Body []byte
+
+	// Err is the kind of the error (one of the Err* variables)
+	// or the error that happened while decoding the response.
+	Err error
+}
+
+func (e *ApiError) Error() string {
+	return fmt.Sprintf("Error Status Code: %d; Code: %d; Message: %s.", e.StatusCode, e.Code, e.Message)
+}
+
+// Unwrap returns the underlying kind of the error.
+func (e *ApiError) Unwrap() error {
+	return e.Err
+}
+
+// envelope holds the Result properties of any response payload.
+// Success is a pointer so payloads without it are not taken as failures.
+type envelope struct {
+	Success *bool
+	Error   ResponseError
+	Message string
+}
+
+// errorKind returns the kind of error for the given HTTP status code.
+func errorKind(statusCode int) error {
+	switch {
+	case statusCode == http.StatusUnauthorized, statusCode == http.StatusForbidden:
+		return ErrAuth
+	case statusCode == http.StatusNotFound:
+		return ErrNotFound
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case statusCode >= http.StatusInternalServerError:
+		return ErrServer
+	default:
+		return ErrValidation
+	}
+}
+
+// checkResponse returns an *ApiError if the response has a non-2xx status
+// code or its payload reports it was not successful.
+func checkResponse(statusCode int, body []byte) error {
+	var env envelope
+	decodeErr := json.Unmarshal(body, &env)
+
+	ok := statusCode >= 200 && statusCode < 300
+	if ok && (decodeErr != nil || env.Success == nil || *env.Success) {
+		return nil
+	}
+
+	msg := env.Message
+	if msg == "" {
+		msg = http.StatusText(statusCode)
+	}
+
+	return &ApiError{
+		StatusCode: statusCode,
+		Code:       env.Error.Code,
+		Message:    msg,
+		Body:       body,
+		Err:        errorKind(statusCode),
+	}
+}
@@ -0,0 +1,80 @@
+package wappa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestCheckResponse(t *testing.T) {
+	testCases := []struct {
+		statusCode int
+		body       string
+		wantKind   error
+		wantCode   int
+		wantMsg    string
+	}{
+		{http.StatusOK, `{}`, nil, 0, ""},
+		{http.StatusOK, `{"success": true}`, nil, 0, ""},
+		{http.StatusOK, `[]`, nil, 0, ""},
+		{http.StatusOK, `{"success": false, "error": {"code": 12}, "message": "Invalid employee"}`, ErrValidation, 12, "Invalid employee"},
+		{http.StatusBadRequest, `{"success": false}`, ErrValidation, 0, http.StatusText(http.StatusBadRequest)},
+		{http.StatusUnauthorized, `{"message": "Unauthorized"}`, ErrAuth, 0, "Unauthorized"},
+		{http.StatusForbidden, ``, ErrAuth, 0, http.StatusText(http.StatusForbidden)},
+		{http.StatusNotFound, `{"success": true}`, ErrNotFound, 0, http.StatusText(http.StatusNotFound)},
+		{http.StatusTooManyRequests, ``, ErrRateLimited, 0, http.StatusText(http.StatusTooManyRequests)},
+		{http.StatusBadGateway, `Bad Gateway`, ErrServer, 0, http.StatusText(http.StatusBadGateway)},
+	}
+
+	for _, tc := range testCases {
+		err := checkResponse(tc.statusCode, []byte(tc.body))
+		if tc.wantKind == nil {
+			if err != nil {
+				t.Errorf("got error from checkResponse(%d, %s): %s; want nil.", tc.statusCode, tc.body, err.Error())
+			}
+			continue
+		}
+
+		var apiErr *ApiError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("got error from checkResponse(%d, %s): %v; want *ApiError.", tc.statusCode, tc.body, err)
+		}
+
+		if !errors.Is(err, tc.wantKind) {
+			t.Errorf("got error kind: %v; want %v.", apiErr.Err, tc.wantKind)
+		}
+
+		if apiErr.StatusCode != tc.statusCode {
+			t.Errorf("got StatusCode: %d; want %d.", apiErr.StatusCode, tc.statusCode)
+		}
+
+		if apiErr.Code != tc.wantCode {
+			t.Errorf("got Code: %d; want %d.", apiErr.Code, tc.wantCode)
+		}
+
+		if apiErr.Message != tc.wantMsg {
+			t.Errorf("got Message: '%s'; want '%s'.", apiErr.Message, tc.wantMsg)
+		}
+
+		if string(apiErr.Body) != tc.body {
+			t.Errorf("got Body: '%s'; want '%s'.", apiErr.Body, tc.body)
+		}
+	}
+}
+
+func TestClientRequestUnsuccessful(t *testing.T) {
+	s := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"success": false, "message": "Ride not found"}`))
+	})
+	defer s.Close()
+
+	u, _ := url.Parse(s.URL)
+	c := NewClient(u, nil)
+
+	_, err := c.Ride.Read(context.Background(), Filter{"id": []string{"1"}})
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("got error calling Ride.Read(): %v; want %v.", err, ErrValidation)
+	}
+}
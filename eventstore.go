@@ -0,0 +1,158 @@
+package wappa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var eventSourceNames = map[EventSource]string{
+	SourcePoll:    "poll",
+	SourceWebhook: "webhook",
+	SourceCreate:  "create",
+	SourceCancel:  "cancel",
+}
+
+// String implements the fmt.Stringer interface.
+func (s EventSource) String() string {
+	if name, ok := eventSourceNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("EventSource(%d)", int(s))
+}
+
+func parseEventSource(name string) (EventSource, error) {
+	for s, n := range eventSourceNames {
+		if n == name {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("wappa: unknown event source %q", name)
+}
+
+// RideEventRecord is a status of a ride seen from one of the sources,
+// as kept by a RideEventStore.
+type RideEventRecord struct {
+	// Assigned by the store.
+	ID         int64
+	RideID     int
+	EmployeeID int
+	ExternalID string
+	Status     RideStatus
+	Source     EventSource
+	// The payload the status was seen in, as JSON.
+	Payload []byte
+	At      time.Time
+}
+
+// NewRideEventRecord returns a record of the ride seen from the source at
+// the current time, i.e. the result of RideService.Create or Read.
+func NewRideEventRecord(source EventSource, r *RideResult) (*RideEventRecord, error) {
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RideEventRecord{
+		RideID:     r.ID,
+		EmployeeID: r.Passenger.ID,
+		ExternalID: r.Info.ExternalID,
+		Status:     r.Info.Status,
+		Source:     source,
+		Payload:    payload,
+		At:         time.Now(),
+	}, nil
+}
+
+// NewWebhookEventRecord returns a record of the ride delivered by the webhook
+// in the payload at the current time.
+func NewWebhookEventRecord(r *WebhookRide, payload []byte) *RideEventRecord {
+	return &RideEventRecord{
+		RideID:     r.RideID,
+		EmployeeID: r.EmployeeID,
+		ExternalID: r.ExternalID,
+		Status:     r.Status,
+		Source:     SourceWebhook,
+		Payload:    payload,
+		At:         time.Now(),
+	}
+}
+
+// RideEventQuery selects records of a RideEventStore. The zero
+// values of the fields don't restrict the records selected.
+type RideEventQuery struct {
+	RideID     int
+	EmployeeID int
+	ExternalID string
+	// Time range of the records, From inclusive and To exclusive.
+	From time.Time
+	To   time.Time
+	// Maximum number of records returned.
+	Limit int
+}
+
+func (q *RideEventQuery) match(r *RideEventRecord) bool {
+	return (q.RideID == 0 || r.RideID == q.RideID) &&
+		(q.EmployeeID == 0 || r.EmployeeID == q.EmployeeID) &&
+		(q.ExternalID == "" || r.ExternalID == q.ExternalID) &&
+		(q.From.IsZero() || !r.At.Before(q.From)) &&
+		(q.To.IsZero() || r.At.Before(q.To))
+}
+
+// RideEventStore keeps the history of the statuses seen for the rides.
+type RideEventStore interface {
+	// Append records the event, setting its ID.
+	Append(ctx context.Context, r *RideEventRecord) error
+	// Query returns the records selected by q, in chronological order.
+	Query(ctx context.Context, q RideEventQuery) ([]*RideEventRecord, error)
+}
+
+// MemoryRideEventStore is an in-process RideEventStore safe for concurrent use.
+type MemoryRideEventStore struct {
+	mu      sync.Mutex
+	records []*RideEventRecord
+}
+
+// NewMemoryRideEventStore returns an empty MemoryRideEventStore.
+func NewMemoryRideEventStore() *MemoryRideEventStore {
+	return &MemoryRideEventStore{}
+}
+
+// Append implements the RideEventStore interface.
+func (s *MemoryRideEventStore) Append(ctx context.Context, r *RideEventRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r.ID = int64(len(s.records) + 1)
+	cp := *r
+	s.records = append(s.records, &cp)
+
+	return nil
+}
+
+// Query implements the RideEventStore interface.
+func (s *MemoryRideEventStore) Query(ctx context.Context, q RideEventQuery) ([]*RideEventRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []*RideEventRecord
+	for _, r := range s.records {
+		if q.match(r) {
+			cp := *r
+			res = append(res, &cp)
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].At.Before(res[j].At)
+	})
+
+	if q.Limit > 0 && len(res) > q.Limit {
+		res = res[:q.Limit]
+	}
+
+	return res, nil
+}
@@ -0,0 +1,199 @@
+package wappa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryRideEventStore(t *testing.T) {
+	testRideEventStore(t, NewMemoryRideEventStore())
+}
+
+func TestNewRideEventRecord(t *testing.T) {
+	r := &RideResult{ID: 7, Passenger: Passenger{ID: 3}, Info: RideInfo{Status: RideStatusDriverFound, ExternalID: "ext"}}
+
+	got, err := NewRideEventRecord(SourceCreate, r)
+	if err != nil {
+		t.Fatalf("got error calling NewRideEventRecord(): %s; want nil.", err.Error())
+	}
+
+	if got.RideID != 7 || got.EmployeeID != 3 || got.ExternalID != "ext" || got.Status != RideStatusDriverFound || got.Source != SourceCreate {
+		t.Errorf("got record: %+v; want the fields of the ride.", got)
+	}
+
+	if !strings.Contains(string(got.Payload), `"rideID":7`) {
+		t.Errorf("got payload: %s; want the ride as JSON.", got.Payload)
+	}
+}
+
+// failingEventStore is a RideEventStore failing to append.
+type failingEventStore struct {
+	RideEventStore
+}
+
+func (failingEventStore) Append(ctx context.Context, r *RideEventRecord) error {
+	return errors.New("failed")
+}
+
+func TestWebhookHandlerEvents(t *testing.T) {
+	testCases := []struct {
+		store      RideEventStore
+		wantStatus int
+		wantCalls  int
+	}{
+		{NewMemoryRideEventStore(), http.StatusOK, 1},
+		{failingEventStore{}, http.StatusInternalServerError, 0},
+	}
+
+	for _, tc := range testCases {
+		var calls int
+		h := NewWebhookHandler(&Webhook{AuthKey: "auth-key"}, func(ctx context.Context, r *WebhookRide) error {
+			calls++
+			return nil
+		})
+		h.Events = tc.store
+
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(testWebhookPayload))
+		req.Header.Set("Authorization", "auth-key")
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != tc.wantStatus || calls != tc.wantCalls {
+			t.Errorf("got status code %d and %d calls; want %d and %d.", rec.Code, calls, tc.wantStatus, tc.wantCalls)
+		}
+
+		s, ok := tc.store.(*MemoryRideEventStore)
+		if !ok {
+			continue
+		}
+
+		got, _ := s.Query(context.Background(), RideEventQuery{RideID: 10})
+		if len(got) != 1 || got[0].Source != SourceWebhook || string(got[0].Payload) != testWebhookPayload {
+			t.Errorf("got records: %+v; want the webhook payload.", got)
+		}
+	}
+}
+
+func TestRideTrackerEvents(t *testing.T) {
+	store := NewMemoryRideEventStore()
+	tr := NewRideTracker(&RideService{&statusRequester{steps: []interface{}{RideStatusSearchingForDriver}}}, &TrackerOptions{
+		FallbackAfter: time.Hour,
+		AutoTrack:     true,
+		Events:        store,
+		Scheduler:     NewWatchScheduler(1),
+	})
+	defer tr.Close()
+
+	for _, r := range []*WebhookRide{
+		{RideID: 1, EmployeeID: 5, ExternalID: "ext", Status: RideStatusDriverFound},
+		{RideID: 1, EmployeeID: 5, ExternalID: "ext", Status: RideStatusDriverFound},
+		{RideID: 1, EmployeeID: 5, ExternalID: "ext", Status: RideStatusInProgress},
+	} {
+		tr.HandleRide(context.Background(), r)
+	}
+
+	got, _ := store.Query(context.Background(), RideEventQuery{EmployeeID: 5, ExternalID: "ext"})
+	if len(got) != 2 || got[0].Status != RideStatusDriverFound || got[1].Status != RideStatusInProgress {
+		t.Errorf("got records: %+v; want driver-found and on-ride.", got)
+	}
+}
+
+// createRequester answers the creation of rides with the ride 9.
+type createRequester struct{}
+
+func (createRequester) Request(ctx context.Context, method string, path endpoint, body, output interface{}) error {
+	if r, ok := output.(*RideResult); ok {
+		r.ID = 9
+	}
+	return nil
+}
+
+func TestRideTrackerCreateCancelEvents(t *testing.T) {
+	store := NewMemoryRideEventStore()
+	tr := NewRideTracker(&RideService{createRequester{}}, &TrackerOptions{
+		FallbackAfter: time.Hour,
+		Events:        store,
+		Scheduler:     NewWatchScheduler(1),
+	})
+	defer tr.Close()
+
+	r := testRide
+	r.ExternalID = "ext"
+	if _, err := tr.Create(context.Background(), &r); err != nil {
+		t.Fatalf("got error calling Create(): %s; want nil.", err.Error())
+	}
+	if _, err := tr.Cancel(context.Background(), 9, 1); err != nil {
+		t.Fatalf("got error calling Cancel(): %s; want nil.", err.Error())
+	}
+
+	got, _ := store.Query(context.Background(), RideEventQuery{RideID: 9})
+	if len(got) != 2 ||
+		got[0].Status != RideStatusSearchingForDriver || got[0].Source != SourceCreate || got[0].ExternalID != "ext" || got[0].EmployeeID != r.EmployeeID ||
+		got[1].Status != RideStatusCancelled || got[1].Source != SourceCancel {
+		t.Errorf("got records: %+v; want the creation and the cancellation.", got)
+	}
+}
+
+// testRideEventStore checks the appending and querying of a RideEventStore.
+func testRideEventStore(t *testing.T, s RideEventStore) {
+	ctx := context.Background()
+	now := time.Unix(1600000000, 0)
+
+	records := []*RideEventRecord{
+		{RideID: 1, EmployeeID: 10, ExternalID: "a", Status: RideStatusSearchingForDriver, Source: SourceCreate, Payload: []byte(`{}`), At: now},
+		{RideID: 1, EmployeeID: 10, ExternalID: "a", Status: RideStatusDriverFound, Source: SourceWebhook, Payload: []byte(`{"rideId":1}`), At: now.Add(time.Minute)},
+		{RideID: 2, EmployeeID: 20, ExternalID: "b", Status: RideStatusCancelled, Source: SourceCancel, At: now.Add(2 * time.Minute)},
+		{RideID: 1, EmployeeID: 10, ExternalID: "a", Status: RideStatusInProgress, Source: SourcePoll, Payload: []byte(`{}`), At: now.Add(3 * time.Minute)},
+		// Appended out of order, and at the same time of the next.
+		{RideID: 1, EmployeeID: 10, ExternalID: "a", Status: RideStatusSearchingForDriver, Source: SourcePoll, At: now.Add(-time.Minute)},
+		{RideID: 1, EmployeeID: 10, ExternalID: "a", Status: RideStatusSearchingForDriver, Source: SourceWebhook, At: now.Add(-time.Minute)},
+	}
+
+	for i, r := range records {
+		if err := s.Append(ctx, r); err != nil {
+			t.Fatalf("got error calling Append(): %s; want nil.", err.Error())
+		}
+		if r.ID != int64(i+1) {
+			t.Errorf("got record ID: %d; want %d.", r.ID, i+1)
+		}
+	}
+
+	testCases := []struct {
+		query RideEventQuery
+		want  []int64
+	}{
+		{RideEventQuery{}, []int64{5, 6, 1, 2, 3, 4}},
+		{RideEventQuery{RideID: 1}, []int64{5, 6, 1, 2, 4}},
+		{RideEventQuery{EmployeeID: 20}, []int64{3}},
+		{RideEventQuery{ExternalID: "a", Limit: 2}, []int64{5, 6}},
+		{RideEventQuery{From: now.Add(time.Minute), To: now.Add(3 * time.Minute)}, []int64{2, 3}},
+		{RideEventQuery{RideID: 3}, nil},
+	}
+
+	for _, tc := range testCases {
+		got, err := s.Query(ctx, tc.query)
+		if err != nil {
+			t.Fatalf("got error calling Query(%+v): %s; want nil.", tc.query, err.Error())
+		}
+
+		var ids []int64
+		for _, r := range got {
+			ids = append(ids, r.ID)
+		}
+		if !reflect.DeepEqual(ids, tc.want) {
+			t.Errorf("got records of Query(%+v): %v; want %v.", tc.query, ids, tc.want)
+		}
+	}
+
+	got, _ := s.Query(ctx, RideEventQuery{RideID: 1, Limit: 4})
+	if want := records[1]; !reflect.DeepEqual(got[3], want) {
+		t.Errorf("got record: %+v; want %+v.", got[3], want)
+	}
+}
@@ -4,5 +4,6 @@ go 1.13
 
 require (
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
+	github.com/mattn/go-sqlite3 v1.14.6
 	golang.org/x/oauth2 v0.0.0-20190604053449-0f29369cfe45
 )
@@ -0,0 +1,233 @@
+package wappa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// Number of times CreateIdempotent tries to create a ride.
+const idempotentCreateAttempts = 3
+
+var (
+	// ErrMissingExternalID is returned by CreateIdempotent when
+	// the ride has no ExternalID to be identified by.
+	ErrMissingExternalID = errors.New("wappa: idempotent ride creation requires Ride.ExternalID")
+	// ErrRideUnconfirmed is returned by CreateIdempotent when a previous
+	// creation of the ride failed and it can't tell if the ride was created.
+	ErrRideUnconfirmed = errors.New("wappa: could not confirm if the ride was created")
+)
+
+// IdempotencyStore keeps the results of rides created by CreateIdempotent,
+// so a ride is not requested twice for the same key.
+type IdempotencyStore interface {
+	// Lock holds the key until the returned function is called,
+	// serializing the creations of the same ride.
+	Lock(key string) (unlock func())
+	// Load returns the result stored for the key, if any.
+	Load(key string) (*RideResult, bool)
+	// Store saves the result of the ride created for the key, or nil if
+	// its creation failed without knowing if the ride was created.
+	Store(key string, r *RideResult)
+}
+
+// MemoryIdempotencyStore is an in-process IdempotencyStore keeping up to a number
+// of results, discarding the least recently used. It is safe for concurrent use.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	locks   map[string]*keyLock
+	results *lru
+}
+
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+// NewMemoryIdempotencyStore returns an empty MemoryIdempotencyStore keeping up to capacity results.
+func NewMemoryIdempotencyStore(capacity int) *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		locks:   make(map[string]*keyLock),
+		results: newLRU(capacity),
+	}
+}
+
+// Lock implements the IdempotencyStore interface.
+func (s *MemoryIdempotencyStore) Lock(key string) func() {
+	s.mu.Lock()
+	l, ok := s.locks[key]
+	if !ok {
+		l = &keyLock{}
+		s.locks[key] = l
+	}
+	l.refs++
+	s.mu.Unlock()
+
+	l.Lock()
+
+	return func() {
+		l.Unlock()
+
+		s.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(s.locks, key)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Load implements the IdempotencyStore interface.
+func (s *MemoryIdempotencyStore) Load(key string) (*RideResult, bool) {
+	v, ok := s.results.get(key)
+	if !ok {
+		return nil, false
+	}
+	return v.(*RideResult), true
+}
+
+// Store implements the IdempotencyStore interface.
+func (s *MemoryIdempotencyStore) Store(key string, r *RideResult) {
+	s.results.put(key, r)
+}
+
+// idempotencyKey returns the key identifying the ride in the IdempotencyStore.
+func idempotencyKey(r *Ride) string {
+	return fmt.Sprintf("%d:%s", r.EmployeeID, r.ExternalID)
+}
+
+// CreateIdempotent creates a new ride in the API, making sure it is created only once
+// for its EmployeeID and ExternalID.
+//
+// When the creation fails without knowing if the ride was created (i.e. connection
+// errors, timeouts or server errors) the ride is looked up in the employee status and
+// last rides before trying again, and the existing ride is returned if found. If the
+// lookup can't tell if the ride was created, the error is returned without trying
+// again, and the ride is looked up first by the next call with the same ExternalID,
+// which returns ErrRideUnconfirmed while it still can't tell.
+//
+// The last rides keep the ExternalID as a number, so they are only looked up for
+// numeric ExternalIDs. Others, i.e. "ext-1", are found only while being the current
+// ride of the employee.
+// Invalid rides are refused before any request is made.
+func (rs *RideService) CreateIdempotent(ctx context.Context, r *Ride, store IdempotencyStore) (*RideResult, error) {
+	if r == nil || r.ExternalID == "" {
+		return nil, ErrMissingExternalID
+	}
+
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	key := idempotencyKey(r)
+
+	unlock := store.Lock(key)
+	defer unlock()
+
+	if res, ok := store.Load(key); ok {
+		if res != nil {
+			return res, nil
+		}
+
+		// A previous creation failed without knowing if the ride was created.
+		existing, err := rs.findByExternalID(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+
+		if existing != nil {
+			store.Store(key, existing)
+			return existing, nil
+		}
+	}
+
+	var err error
+	for attempt := 0; attempt < idempotentCreateAttempts; attempt++ {
+		var res *RideResult
+		if res, err = rs.Create(ctx, r); err == nil {
+			store.Store(key, res)
+			return res, nil
+		}
+
+		if !ambiguous(err) {
+			return nil, err
+		}
+
+		if ctx.Err() != nil {
+			store.Store(key, nil)
+			return nil, err
+		}
+
+		existing, lookupErr := rs.findByExternalID(ctx, r)
+		if lookupErr != nil {
+			// Can't tell if the ride was created, so it is not safe to try again.
+			store.Store(key, nil)
+			return nil, err
+		}
+
+		if existing != nil {
+			store.Store(key, existing)
+			return existing, nil
+		}
+	}
+
+	return nil, err
+}
+
+// ambiguous reports if a failed request may have been processed by the API.
+func ambiguous(err error) bool {
+	if errors.Is(err, ErrValidation) {
+		return false
+	}
+
+	var apiErr *ApiError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	return errors.Is(apiErr, ErrServer)
+}
+
+// findByExternalID returns the ride of the employee with the same ExternalID
+// of r, or nil if the ride was not created. It returns ErrRideUnconfirmed if
+// the ride is not found but the ExternalID can't be looked up in the last rides.
+func (rs *RideService) findByExternalID(ctx context.Context, r *Ride) (*RideResult, error) {
+	es := (*EmployeeService)(rs)
+
+	st, err := es.Status(ctx, r.EmployeeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if st.RideID != 0 {
+		cur, err := rs.Read(ctx, Filter{"id": []string{strconv.Itoa(st.RideID)}})
+		if err != nil {
+			return nil, err
+		}
+
+		if cur.Info.ExternalID == r.ExternalID {
+			return cur, nil
+		}
+	}
+
+	// The last rides only have numeric external IDs.
+	if _, err := strconv.Atoi(r.ExternalID); err != nil {
+		return nil, ErrRideUnconfirmed
+	}
+
+	lr, err := es.LastRides(ctx, Filter{
+		"employee":   []string{strconv.Itoa(r.EmployeeID)},
+		"externalID": []string{r.ExternalID},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, h := range lr.History {
+		if strconv.Itoa(h.Info.ExternalID) == r.ExternalID {
+			return rs.Read(ctx, Filter{"id": []string{strconv.Itoa(h.ID)}})
+		}
+	}
+
+	return nil, nil
+}
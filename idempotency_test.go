@@ -0,0 +1,221 @@
+package wappa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// rideAPI is a fake of the ride creation and lookup endpoints.
+type rideAPI struct {
+	requests int32
+	creates  int32
+	// Creations answered with a server error, even though the ride is created.
+	failures int32
+	// Status of the create response after failures.
+	createStatus int
+	created      bool
+}
+
+func (a *rideAPI) server() *httptest.Server {
+	var mu sync.Mutex
+
+	return newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		atomic.AddInt32(&a.requests, 1)
+
+		switch r.URL.Path {
+		case "/api/ride":
+			n := atomic.AddInt32(&a.creates, 1)
+			if a.createStatus != 0 {
+				w.WriteHeader(a.createStatus)
+				return
+			}
+			a.created = true
+			if n <= a.failures {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.Write([]byte(`{"success": true, "rideID": 7, "rideInfo": {"externalId": "ext-1"}}`))
+		case "/api/employee/status":
+			w.Write([]byte(`{"rideId": 0}`))
+		case "/api/employee/last-rides":
+			if !a.created {
+				w.Write([]byte(`{"success": true, "history": []}`))
+				return
+			}
+			w.Write([]byte(`{"success": true, "history": [{"rideId": 7, "rideInfo": {"externalId": 1}}]}`))
+		case "/api/ride/status":
+			w.Write([]byte(`{"success": true, "rideID": 7, "rideInfo": {"externalId": "1"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestRideCreateIdempotent(t *testing.T) {
+	testCases := []struct {
+		name        string
+		api         *rideAPI
+		externalID  string
+		wantCreates int32
+		wantID      int
+		wantErr     error
+	}{
+		{"created", &rideAPI{}, "ext-1", 1, 7, nil},
+		{"found after ambiguous failure", &rideAPI{failures: 3}, "1", 1, 7, nil},
+		// Not in the last rides, which only have numeric external IDs.
+		{"non-numeric not found after ambiguous failure", &rideAPI{failures: 1}, "ext-1", 1, 0, ErrServer},
+		{"not created", &rideAPI{failures: 1, createStatus: http.StatusBadGateway}, "1", idempotentCreateAttempts, 0, ErrServer},
+		{"not ambiguous", &rideAPI{createStatus: http.StatusBadRequest}, "1", 1, 0, ErrValidation},
+		{"missing external ID", &rideAPI{}, "", 0, 0, ErrMissingExternalID},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := tc.api.server()
+			defer s.Close()
+
+			u, _ := url.Parse(s.URL)
+			c := NewClient(u, nil)
+
+			r := testRide
+			r.ExternalID = tc.externalID
+			res, err := c.Ride.CreateIdempotent(context.Background(), &r, NewMemoryIdempotencyStore(10))
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("got error calling Ride.CreateIdempotent(): %v; want %v.", err, tc.wantErr)
+			}
+
+			if got := atomic.LoadInt32(&tc.api.creates); got != tc.wantCreates {
+				t.Errorf("got %d creations; want %d.", got, tc.wantCreates)
+			}
+
+			if err == nil && res.ID != tc.wantID {
+				t.Errorf("got RideResult.ID: %d; want %d.", res.ID, tc.wantID)
+			}
+		})
+	}
+}
+
+func TestRideCreateIdempotentInvalid(t *testing.T) {
+	api := &rideAPI{}
+	s := api.server()
+	defer s.Close()
+
+	u, _ := url.Parse(s.URL)
+	c := NewClient(u, nil)
+
+	_, err := c.Ride.CreateIdempotent(context.Background(), &Ride{EmployeeID: 1, ExternalID: "x"}, NewMemoryIdempotencyStore(10))
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("got error calling Ride.CreateIdempotent(): %v; want %v.", err, ErrValidation)
+	}
+
+	if got := atomic.LoadInt32(&api.requests); got != 0 {
+		t.Errorf("got %d requests; want 0.", got)
+	}
+}
+
+func TestRideCreateIdempotentStore(t *testing.T) {
+	api := &rideAPI{}
+	s := api.server()
+	defer s.Close()
+
+	u, _ := url.Parse(s.URL)
+	c := NewClient(u, nil)
+	store := NewMemoryIdempotencyStore(10)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := testRide
+			r.ExternalID = "ext-1"
+			if _, err := c.Ride.CreateIdempotent(context.Background(), &r, store); err != nil {
+				t.Errorf("got error calling Ride.CreateIdempotent(): %s; want nil.", err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&api.creates); got != 1 {
+		t.Errorf("got %d creations; want 1.", got)
+	}
+
+	if len(store.locks) != 0 {
+		t.Errorf("got %d locks held; want 0.", len(store.locks))
+	}
+}
+
+func TestRideCreateIdempotentPending(t *testing.T) {
+	testCases := []struct {
+		name        string
+		api         *rideAPI
+		externalID  string
+		wantCreates int32
+		wantID      int
+		wantErr     error
+	}{
+		{"found", &rideAPI{created: true}, "1", 0, 7, nil},
+		{"not created", &rideAPI{}, "1", 1, 7, nil},
+		{"non-numeric unconfirmed", &rideAPI{created: true}, "ext-1", 0, 0, ErrRideUnconfirmed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := tc.api.server()
+			defer s.Close()
+
+			u, _ := url.Parse(s.URL)
+			c := NewClient(u, nil)
+
+			r := testRide
+			r.ExternalID = tc.externalID
+			store := NewMemoryIdempotencyStore(10)
+			store.Store(idempotencyKey(&r), nil)
+
+			res, err := c.Ride.CreateIdempotent(context.Background(), &r, store)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("got error calling Ride.CreateIdempotent(): %v; want %v.", err, tc.wantErr)
+			}
+
+			if got := atomic.LoadInt32(&tc.api.creates); got != tc.wantCreates {
+				t.Errorf("got %d creations; want %d.", got, tc.wantCreates)
+			}
+
+			if err == nil && res.ID != tc.wantID {
+				t.Errorf("got RideResult.ID: %d; want %d.", res.ID, tc.wantID)
+			}
+		})
+	}
+}
+
+func TestRideCreateIdempotentCanceled(t *testing.T) {
+	api := &rideAPI{}
+	s := api.server()
+	defer s.Close()
+
+	u, _ := url.Parse(s.URL)
+	c := NewClient(u, nil)
+	store := NewMemoryIdempotencyStore(10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	r := testRide
+	r.ExternalID = "1"
+	if _, err := c.Ride.CreateIdempotent(ctx, &r, store); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error calling Ride.CreateIdempotent(): %v; want %v.", err, context.Canceled)
+	}
+
+	if res, ok := store.Load(idempotencyKey(&r)); !ok || res != nil {
+		t.Errorf("got stored result: %v, %t; want pending.", res, ok)
+	}
+}
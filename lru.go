@@ -0,0 +1,86 @@
+package wappa
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lru is a map keeping up to a number of keys, discarding the
+// least recently used. It is safe for concurrent use.
+type lru struct {
+	capacity int
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value interface{}
+}
+
+// newLRU returns an lru keeping up to capacity keys, at least one.
+func newLRU(capacity int) *lru {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &lru{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// get returns the value of the key, marking it as the most recently used.
+func (l *lru) get(key string) (interface{}, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	l.ll.MoveToFront(e)
+
+	return e.Value.(*lruEntry).value, true
+}
+
+// put sets the value of the key, discarding the least recently used key if full.
+func (l *lru) put(key string, value interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e, ok := l.items[key]; ok {
+		l.ll.MoveToFront(e)
+		e.Value.(*lruEntry).value = value
+		return
+	}
+
+	l.items[key] = l.ll.PushFront(&lruEntry{key, value})
+
+	if l.ll.Len() > l.capacity {
+		oldest := l.ll.Back()
+		l.ll.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// remove deletes the key.
+func (l *lru) remove(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e, ok := l.items[key]; ok {
+		l.ll.Remove(e)
+		delete(l.items, key)
+	}
+}
+
+// len returns the number of keys.
+func (l *lru) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.ll.Len()
+}
@@ -0,0 +1,110 @@
+package wappa
+
+import (
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// ClientOption configures a Client created by NewClient.
+type ClientOption func(*Client)
+
+// Middleware wraps the http.RoundTripper used to send the requests
+// of the Client, intercepting all requests made by the services.
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// WithUserAgent sets the User-Agent header sent in every request.
+func WithUserAgent(ua string) ClientOption {
+	return func(c *Client) {
+		c.userAgent = ua
+	}
+}
+
+// WithTimeout sets the default timeout of the requests made with
+// a context without deadline, including their retries.
+func WithTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithHeader adds a header sent in every request.
+func WithHeader(key, value string) ClientOption {
+	return func(c *Client) {
+		c.header.Add(key, value)
+	}
+}
+
+// WithTokenSource authenticates the requests with the tokens from ts.
+func WithTokenSource(ts oauth2.TokenSource) ClientOption {
+	return func(c *Client) {
+		c.tokenSource = ts
+	}
+}
+
+// WithRateLimiter limits the rate of the requests with l.
+func WithRateLimiter(l *RateLimiter) ClientOption {
+	return func(c *Client) {
+		c.limiter = l
+	}
+}
+
+// WithTokenCache sets the cache of the tokens of a client
+// created by NewClientWithCredentials.
+func WithTokenCache(cache TokenCache) ClientOption {
+	return func(c *Client) {
+		c.tokenCache = cache
+	}
+}
+
+// WithTokenVerifyKey sets the public key used to verify the signature of the
+// tokens of a client created by NewClientWithCredentials. See TokenSource.VerifyKey.
+func WithTokenVerifyKey(key interface{}) ClientOption {
+	return func(c *Client) {
+		c.tokenVerifyKey = key
+	}
+}
+
+// WithMiddleware appends middlewares to the chain the requests go through.
+// The first middleware sees the requests first, already authenticated
+// if a token source is set.
+func WithMiddleware(mw ...Middleware) ClientOption {
+	return func(c *Client) {
+		c.middleware = append(c.middleware, mw...)
+	}
+}
+
+// WithRetryPolicy sets the policy used to retry failed requests.
+// A nil policy disables retries.
+func WithRetryPolicy(p *RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.Retry = p
+	}
+}
+
+// transport returns a copy of the HTTP client with its transport
+// wrapped by the token source and the middleware chain, if any.
+func (c *Client) transport(client *http.Client) *http.Client {
+	if c.tokenSource == nil && len(c.middleware) == 0 {
+		return client
+	}
+
+	rt := client.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		rt = c.middleware[i](rt)
+	}
+
+	if c.tokenSource != nil {
+		rt = &oauth2.Transport{Source: c.tokenSource, Base: rt}
+	}
+
+	hc := *client
+	hc.Transport = rt
+
+	return &hc
+}
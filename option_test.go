@@ -0,0 +1,100 @@
+package wappa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestNewClientOptions(t *testing.T) {
+	var calls []string
+	middleware := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, name)
+				if got, want := req.Header.Get("Authorization"), "Bearer token"; got != want {
+					t.Errorf("got Authorization header in middleware: '%s'; want '%s'.", got, want)
+				}
+				return next.RoundTrip(req)
+			})
+		}
+	}
+
+	s := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("User-Agent"), "wappa-test/1.0"; got != want {
+			t.Errorf("got User-Agent header: '%s'; want '%s'.", got, want)
+		}
+
+		if got, want := r.Header["X-Company"], []string{"1", "2"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got X-Company header: %v; want %v.", got, want)
+		}
+
+		if got, want := r.Header.Get("Authorization"), "Bearer token"; got != want {
+			t.Errorf("got Authorization header: '%s'; want '%s'.", got, want)
+		}
+
+		w.Write([]byte(`{}`))
+	})
+	defer s.Close()
+
+	u, _ := url.Parse(s.URL)
+	hc := &http.Client{}
+	c := NewClient(u, hc,
+		WithUserAgent("wappa-test/1.0"),
+		WithHeader("X-Company", "1"),
+		WithHeader("X-Company", "2"),
+		WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"})),
+		WithMiddleware(middleware("first"), middleware("second")),
+		WithRetryPolicy(nil),
+	)
+
+	if hc.Transport != nil {
+		t.Errorf("got provided HTTP client transport changed; want it untouched.")
+	}
+
+	if c.Retry != nil {
+		t.Errorf("got Retry: %+v; want nil.", c.Retry)
+	}
+
+	if err := c.Request(context.Background(), http.MethodGet, "", nil, &Result{}); err != nil {
+		t.Fatalf("got error calling Client.Request(): %s; want nil.", err.Error())
+	}
+
+	if ok, err := c.Status(context.Background()); !ok || err != nil {
+		t.Fatalf("got Client.Status(): %t, %v; want true, nil.", ok, err)
+	}
+
+	if want := []string{"first", "second", "first", "second"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("got middleware calls: %v; want %v.", calls, want)
+	}
+}
+
+func TestNewClientWithTimeout(t *testing.T) {
+	s := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	defer s.Close()
+
+	u, _ := url.Parse(s.URL)
+	c := NewClient(u, nil, WithTimeout(10*time.Millisecond))
+
+	err := c.Request(context.Background(), http.MethodGet, "", nil, &Result{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error: %v; want %v.", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,249 @@
+package wappa
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// QuoteOption is a subcategory of a quote, as compared by a QuotePolicy.
+type QuoteOption struct {
+	Category    *Category
+	SubCategory *SubCategory
+	// Price range after the discount.
+	MinPrice float64
+	MaxPrice float64
+	Pickup   time.Duration
+	Journey  time.Duration
+	// Score from 0 to 1 in the weighted mode, the higher the better.
+	Score float64
+	// Reasons explain the rank of the option, or why it was rejected.
+	Reasons []string
+}
+
+// QuoteOptions flattens the subcategories of the quote into options, in order.
+// Their prices are the ones of SubCategory.DiscountedEstimate.
+func QuoteOptions(q *QuoteResult) []*QuoteOption {
+	var opts []*QuoteOption
+	for _, c := range q.Categories {
+		for i := range c.SubCategories {
+			s := &c.SubCategories[i]
+			e := s.DiscountedEstimate()
+			opts = append(opts, &QuoteOption{
+				Category:    c,
+				SubCategory: s,
+				MinPrice:    e.Minimum,
+				MaxPrice:    e.Maximum,
+				Pickup:      s.Estimate.TimeToPickup.Duration,
+				Journey:     s.Estimate.Journey.Duration,
+			})
+		}
+	}
+	return opts
+}
+
+// QuoteSort is the order of the options ranked by a QuotePolicy.
+type QuoteSort int
+
+// Orders of QuotePolicy.
+const (
+	// Lowest maximum price first.
+	SortByPrice QuoteSort = iota
+	// Shortest time to pickup first.
+	SortByPickup
+	// Shortest journey first.
+	SortByJourney
+	// Highest weighted score first. See QuoteWeights.
+	SortByScore
+)
+
+// QuoteWeights are the weights of the criteria in the
+// weighted score. Only their proportion matters.
+type QuoteWeights struct {
+	Price   float64
+	Pickup  float64
+	Journey float64
+}
+
+// QuotePolicy filters and ranks the options of quotes. The zero value
+// accepts every option, ranking them by price.
+type QuotePolicy struct {
+	// IDs of the categories allowed. All are allowed if empty.
+	AllowedCategories []int
+	// Upper limits of the options accepted, ignored if zero.
+	MaxPrice   float64
+	MaxPickup  time.Duration
+	MaxJourney time.Duration
+	SortBy     QuoteSort
+	// Weights of the SortByScore order.
+	Weights QuoteWeights
+}
+
+// QuoteRanking is the result of a QuotePolicy over a quote.
+type QuoteRanking struct {
+	// Options accepted, best first.
+	Options []*QuoteOption
+	// Options rejected, with the reasons why.
+	Rejected []*QuoteOption
+}
+
+// Best returns the best option, or nil if all were rejected.
+func (r *QuoteRanking) Best() *QuoteOption {
+	if len(r.Options) == 0 {
+		return nil
+	}
+	return r.Options[0]
+}
+
+// Rank filters and ranks the options of the quote.
+func (p *QuotePolicy) Rank(q *QuoteResult) *QuoteRanking {
+	r := &QuoteRanking{}
+	for _, o := range QuoteOptions(q) {
+		if reasons := p.reject(o); len(reasons) > 0 {
+			o.Reasons = reasons
+			r.Rejected = append(r.Rejected, o)
+			continue
+		}
+		r.Options = append(r.Options, o)
+	}
+
+	if p.SortBy == SortByScore {
+		p.score(r.Options)
+	}
+
+	sort.SliceStable(r.Options, func(i, j int) bool {
+		a, b := r.Options[i], r.Options[j]
+		switch p.SortBy {
+		case SortByPickup:
+			return a.Pickup < b.Pickup
+		case SortByJourney:
+			return a.Journey < b.Journey
+		case SortByScore:
+			return a.Score > b.Score
+		default:
+			return a.MaxPrice < b.MaxPrice
+		}
+	})
+
+	for i, o := range r.Options {
+		o.Reasons = append([]string{p.explain(o, i)}, o.Reasons...)
+	}
+
+	return r
+}
+
+// Selector returns a QuoteSelector of the best option of the policy.
+func (p *QuotePolicy) Selector() QuoteSelector {
+	return func(q *QuoteResult) (*Category, *SubCategory) {
+		if best := p.Rank(q).Best(); best != nil {
+			return best.Category, best.SubCategory
+		}
+		return nil, nil
+	}
+}
+
+// reject returns why the option is not accepted by the policy.
+func (p *QuotePolicy) reject(o *QuoteOption) []string {
+	var reasons []string
+
+	if len(p.AllowedCategories) > 0 {
+		allowed := false
+		for _, id := range p.AllowedCategories {
+			allowed = allowed || id == o.Category.ID
+		}
+		if !allowed {
+			reasons = append(reasons, fmt.Sprintf("category %s not allowed", o.Category.Description))
+		}
+	}
+	if p.MaxPrice > 0 && o.MaxPrice > p.MaxPrice {
+		reasons = append(reasons, fmt.Sprintf("price up to %.2f above the limit of %.2f", o.MaxPrice, p.MaxPrice))
+	}
+	if p.MaxPickup > 0 && o.Pickup > p.MaxPickup {
+		reasons = append(reasons, fmt.Sprintf("pickup in %s above the limit of %s", o.Pickup, p.MaxPickup))
+	}
+	if p.MaxJourney > 0 && o.Journey > p.MaxJourney {
+		reasons = append(reasons, fmt.Sprintf("journey of %s above the limit of %s", o.Journey, p.MaxJourney))
+	}
+
+	return reasons
+}
+
+// score sets the weighted score of the options. Each criterion is scaled
+// between the best and the worst option, so the best scores 1 and the worst 0.
+func (p *QuotePolicy) score(opts []*QuoteOption) {
+	w := p.Weights
+	total := w.Price + w.Pickup + w.Journey
+	if total <= 0 {
+		w, total = QuoteWeights{Price: 1}, 1
+	}
+
+	price := scale(opts, func(o *QuoteOption) float64 { return o.MaxPrice })
+	pickup := scale(opts, func(o *QuoteOption) float64 { return float64(o.Pickup) })
+	journey := scale(opts, func(o *QuoteOption) float64 { return float64(o.Journey) })
+
+	for i, o := range opts {
+		o.Score = (w.Price*price[i] + w.Pickup*pickup[i] + w.Journey*journey[i]) / total
+		o.Reasons = append(o.Reasons, fmt.Sprintf("price %.2f, pickup %.2f and journey %.2f weighted %g, %g and %g",
+			price[i], pickup[i], journey[i], w.Price, w.Pickup, w.Journey))
+	}
+}
+
+// scale returns the values of the options scaled from 1, the lowest, to 0, the highest.
+func scale(opts []*QuoteOption, value func(o *QuoteOption) float64) []float64 {
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, o := range opts {
+		min, max = math.Min(min, value(o)), math.Max(max, value(o))
+	}
+
+	scaled := make([]float64, len(opts))
+	for i, o := range opts {
+		if max > min {
+			scaled[i] = (max - value(o)) / (max - min)
+		} else {
+			scaled[i] = 1
+		}
+	}
+	return scaled
+}
+
+// explain describes the rank of the option.
+func (p *QuotePolicy) explain(o *QuoteOption, rank int) string {
+	name := o.Category.Description + " " + o.SubCategory.Description
+	if rank > 0 {
+		name = fmt.Sprintf("#%d %s", rank+1, name)
+	} else {
+		name = "best: " + name
+	}
+
+	price := fmt.Sprintf("%.2f to %.2f", o.MinPrice, o.MaxPrice)
+	if d := o.SubCategory.Discount; d > 0 {
+		price += fmt.Sprintf(" after %.2f discount", d)
+	}
+
+	switch p.SortBy {
+	case SortByPickup:
+		return fmt.Sprintf("%s, pickup in %s, price %s", name, o.Pickup, price)
+	case SortByJourney:
+		return fmt.Sprintf("%s, journey of %s, price %s", name, o.Journey, price)
+	case SortByScore:
+		return fmt.Sprintf("%s, score %.2f, price %s", name, o.Score, price)
+	default:
+		return fmt.Sprintf("%s, price %s", name, price)
+	}
+}
+
+// QuotePolicies are the policies of the companies by their ID. The policy
+// of ID 0, if any, is used for the companies without their own.
+type QuotePolicies map[int]*QuotePolicy
+
+// For returns the policy of the company, the default one, or the zero QuotePolicy.
+func (ps QuotePolicies) For(companyID int) *QuotePolicy {
+	if p, ok := ps[companyID]; ok {
+		return p
+	}
+	if p, ok := ps[0]; ok {
+		return p
+	}
+	return &QuotePolicy{}
+}
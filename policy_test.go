@@ -0,0 +1,115 @@
+package wappa
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rankTypes returns the TypeID of the options.
+func rankTypes(opts []*QuoteOption) []int {
+	var types []int
+	for _, o := range opts {
+		types = append(types, o.SubCategory.TypeID)
+	}
+	return types
+}
+
+func TestQuoteOptions(t *testing.T) {
+	q := testQuote(time.Now())
+	q.Categories[0].SubCategories[1].Discount = 20
+
+	opts := QuoteOptions(q)
+	if got := rankTypes(opts); !reflect.DeepEqual(got, []int{10, 11, 20}) {
+		t.Fatalf("got options: %v; want [10 11 20].", got)
+	}
+
+	if o := opts[1]; o.MinPrice != 0 || o.MaxPrice != 25 || o.Category.ID != 1 || o.Pickup != 2*time.Minute {
+		t.Errorf("got option: %+v; want discounted prices 0 to 25 of category 1.", o)
+	}
+}
+
+func TestQuotePolicyRank(t *testing.T) {
+	q := testQuote(time.Now())
+	q.Categories[0].SubCategories[1].Discount = 22
+	q.Categories[0].SubCategories[0].Estimate.Journey = DurationMin{30 * time.Minute}
+	q.Categories[0].SubCategories[1].Estimate.Journey = DurationMin{20 * time.Minute}
+	q.Categories[1].SubCategories[0].Estimate.Journey = DurationMin{40 * time.Minute}
+
+	testCases := []struct {
+		name         string
+		policy       *QuotePolicy
+		want         []int
+		wantRejected []int
+	}{
+		{"price", &QuotePolicy{}, []int{11, 20, 10}, nil},
+		{"pickup", &QuotePolicy{SortBy: SortByPickup}, []int{11, 10, 20}, nil},
+		{"journey", &QuotePolicy{SortBy: SortByJourney}, []int{11, 10, 20}, nil},
+		{"allowed categories", &QuotePolicy{AllowedCategories: []int{2}}, []int{20}, []int{10, 11}},
+		{"limits", &QuotePolicy{MaxPrice: 28, MaxPickup: 6 * time.Minute}, []int{11}, []int{10, 20}},
+		{"score", &QuotePolicy{SortBy: SortByScore, Weights: QuoteWeights{Pickup: 1}}, []int{11, 10, 20}, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := tc.policy.Rank(q)
+
+			if got := rankTypes(r.Options); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got ranked options: %v; want %v.", got, tc.want)
+			}
+
+			if got := rankTypes(r.Rejected); !reflect.DeepEqual(got, tc.wantRejected) {
+				t.Errorf("got rejected options: %v; want %v.", got, tc.wantRejected)
+			}
+
+			for _, o := range append(r.Options, r.Rejected...) {
+				if len(o.Reasons) == 0 {
+					t.Errorf("got option %d without reasons; want explained.", o.SubCategory.TypeID)
+				}
+			}
+
+			if best := r.Best(); !strings.HasPrefix(best.Reasons[0], "best: ") {
+				t.Errorf("got best option explanation: %s; want it flagged as best.", best.Reasons[0])
+			}
+		})
+	}
+}
+
+func TestQuotePolicyScore(t *testing.T) {
+	r := (&QuotePolicy{SortBy: SortByScore, Weights: QuoteWeights{Price: 1, Pickup: 1}}).Rank(testQuote(time.Now()))
+
+	// Executivo is the fastest and most expensive, Pop the cheapest and slowest.
+	want := map[int]float64{10: (0.75 + 0.5) / 2, 11: 0.5, 20: 0.5}
+	for _, o := range r.Options {
+		if w := want[o.SubCategory.TypeID]; o.Score < w-1e-9 || o.Score > w+1e-9 {
+			t.Errorf("got score of %d: %f; want %f.", o.SubCategory.TypeID, o.Score, w)
+		}
+	}
+}
+
+func TestQuotePolicySelector(t *testing.T) {
+	b := NewRideBuilder(7, Location{1, 1}, Location{2, 2})
+
+	r, err := b.Build(testQuote(time.Now()), (&QuotePolicy{SortBy: SortByPickup}).Selector())
+	if err != nil || r.TaxiTypeID != 11 {
+		t.Errorf("got ride: %+v, %v; want type 11, nil.", r, err)
+	}
+
+	if _, err := b.Build(testQuote(time.Now()), (&QuotePolicy{MaxPrice: 1}).Selector()); err != ErrNoQuoteSelected {
+		t.Errorf("got error: %v; want %v.", err, ErrNoQuoteSelected)
+	}
+}
+
+func TestQuotePolicies(t *testing.T) {
+	def, own := &QuotePolicy{}, &QuotePolicy{SortBy: SortByPickup}
+	ps := QuotePolicies{0: def, 5: own}
+
+	if ps.For(5) != own || ps.For(6) != def {
+		t.Errorf("got policies of companies 5 and 6 not their own and the default.")
+	}
+
+	if p := (QuotePolicies{}).For(5); p == nil {
+		t.Errorf("got nil policy without default; want the zero QuotePolicy.")
+	}
+}
@@ -0,0 +1,151 @@
+package wappa
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// CredentialsProvider returns the Wappa credentials of the companies.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context, companyID int) (username, password string, err error)
+}
+
+// CredentialsProviderFunc is an adapter to allow the use of ordinary functions as CredentialsProvider.
+type CredentialsProviderFunc func(ctx context.Context, companyID int) (username, password string, err error)
+
+// Credentials calls f(ctx, companyID).
+func (f CredentialsProviderFunc) Credentials(ctx context.Context, companyID int) (string, string, error) {
+	return f(ctx, companyID)
+}
+
+// ClientPool keeps an authenticated Client per company, as seen in
+// WebhookRide.CompanyID and RideHistory.CompanyID. Clients are created
+// on their first use and share the same HTTP client and connection pool.
+// It is safe for concurrent use.
+type ClientPool struct {
+	host        *url.URL
+	provider    CredentialsProvider
+	client      *http.Client
+	opts        []ClientOption
+	idleTimeout time.Duration
+
+	mu      sync.Mutex
+	tenants map[int]*tenant
+}
+
+type tenant struct {
+	client   *Client
+	lastUsed time.Time
+	// Closed when the client is created, or failed to be.
+	ready chan struct{}
+	err   error
+}
+
+// NewClientPool returns a ClientPool creating clients for host with the credentials
+// of provider. A nil client uses http.DefaultClient. Clients unused for longer than
+// idleTimeout are evicted; a zero idleTimeout keeps them forever.
+func NewClientPool(host *url.URL, provider CredentialsProvider, client *http.Client, idleTimeout time.Duration, opts ...ClientOption) *ClientPool {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &ClientPool{
+		host:        host,
+		provider:    provider,
+		client:      client,
+		opts:        opts,
+		idleTimeout: idleTimeout,
+		tenants:     make(map[int]*tenant),
+	}
+}
+
+// Client returns the client of the company, creating it if needed.
+func (p *ClientPool) Client(ctx context.Context, companyID int) (*Client, error) {
+	now := time.Now()
+
+	p.mu.Lock()
+	p.evict(now)
+
+	t, ok := p.tenants[companyID]
+	if !ok {
+		t = &tenant{ready: make(chan struct{})}
+		p.tenants[companyID] = t
+	}
+	t.lastUsed = now
+	p.mu.Unlock()
+
+	if !ok {
+		t.client, t.err = p.newClient(ctx, companyID)
+		if t.err != nil {
+			p.mu.Lock()
+			if p.tenants[companyID] == t {
+				delete(p.tenants, companyID)
+			}
+			p.mu.Unlock()
+		}
+		close(t.ready)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-t.ready:
+	}
+
+	return t.client, t.err
+}
+
+func (p *ClientPool) newClient(ctx context.Context, companyID int) (*Client, error) {
+	username, password, err := p.provider.Credentials(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens are fetched with the shared client, outliving the given context.
+	tokenCtx := context.WithValue(context.Background(), oauth2.HTTPClient, p.client)
+
+	return NewClientWithCredentials(tokenCtx, p.host, username, password, p.opts...), nil
+}
+
+// Evict removes the client of the company from the pool.
+func (p *ClientPool) Evict(companyID int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.tenants, companyID)
+}
+
+// EvictIdle removes the clients unused for longer than the idle timeout.
+// Idle clients are also evicted whenever a client is requested.
+func (p *ClientPool) EvictIdle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.evict(time.Now())
+}
+
+// Len returns the number of companies with a client in the pool.
+func (p *ClientPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.tenants)
+}
+
+// evict removes the idle clients. It must be called holding the lock.
+func (p *ClientPool) evict(now time.Time) {
+	if p.idleTimeout <= 0 {
+		return
+	}
+
+	for id, t := range p.tenants {
+		if now.Sub(t.lastUsed) > p.idleTimeout {
+			delete(p.tenants, id)
+		}
+	}
+}
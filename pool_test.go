@@ -0,0 +1,129 @@
+package wappa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func poolServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			r.ParseForm()
+			claims := &jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Hour).Unix(), Subject: r.Form.Get("username")}
+			ss, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("AllYourBase"))
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(fmt.Sprintf(`{"access_token": "%s"}`, ss)))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+}
+
+func TestClientPool(t *testing.T) {
+	s := poolServer()
+	defer s.Close()
+
+	var calls int32
+	provider := CredentialsProviderFunc(func(ctx context.Context, companyID int) (string, string, error) {
+		atomic.AddInt32(&calls, 1)
+		if companyID == 0 {
+			return "", "", errors.New("unknown company")
+		}
+		return fmt.Sprintf("user-%d", companyID), "pass", nil
+	})
+
+	u, _ := url.Parse(s.URL)
+	p := NewClientPool(u, provider, nil, 0)
+
+	var wg sync.WaitGroup
+	clients := make([]*Client, 5)
+	for i := range clients {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c, err := p.Client(context.Background(), 1)
+			if err != nil {
+				t.Errorf("got error calling ClientPool.Client(1): %s; want nil.", err.Error())
+			}
+			clients[i] = c
+		}(i)
+	}
+	wg.Wait()
+
+	for _, c := range clients {
+		if c != clients[0] {
+			t.Fatalf("got different clients for the same company; want the same.")
+		}
+	}
+
+	claims, err := clients[0].TokenClaims()
+	if err != nil {
+		t.Fatalf("got error calling TokenClaims(): %s; want nil.", err.Error())
+	}
+
+	if claims.Subject != "user-1" {
+		t.Errorf("got Claims.Subject: %s; want 'user-1'.", claims.Subject)
+	}
+
+	other, err := p.Client(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("got error calling ClientPool.Client(2): %s; want nil.", err.Error())
+	}
+
+	if other == clients[0] {
+		t.Errorf("got the same client for different companies; want different.")
+	}
+
+	if _, err := p.Client(context.Background(), 0); err == nil {
+		t.Errorf("got error nil for company without credentials; want not nil.")
+	}
+
+	if got := p.Len(); got != 2 {
+		t.Errorf("got %d clients in the pool; want 2.", got)
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Errorf("got %d calls to the provider; want 3.", got)
+	}
+
+	p.Evict(2)
+	if got := p.Len(); got != 1 {
+		t.Errorf("got %d clients in the pool after Evict(); want 1.", got)
+	}
+}
+
+func TestClientPoolIdle(t *testing.T) {
+	s := poolServer()
+	defer s.Close()
+
+	provider := CredentialsProviderFunc(func(ctx context.Context, companyID int) (string, string, error) {
+		return "user", "pass", nil
+	})
+
+	u, _ := url.Parse(s.URL)
+	p := NewClientPool(u, provider, nil, 10*time.Millisecond)
+
+	first, _ := p.Client(context.Background(), 1)
+
+	time.Sleep(20 * time.Millisecond)
+	p.EvictIdle()
+
+	if got := p.Len(); got != 0 {
+		t.Errorf("got %d clients in the pool; want 0.", got)
+	}
+
+	if second, _ := p.Client(context.Background(), 1); second == first {
+		t.Errorf("got the evicted client; want a new one.")
+	}
+}
@@ -2,6 +2,7 @@ package wappa
 
 import (
 	"context"
+	"math"
 	"net/http"
 	"time"
 )
@@ -33,6 +34,8 @@ type Icon struct {
 }
 
 // The available subcategories for this category.
+// The Discount is an amount off the estimate, as the rideDiscount
+// off the rideOriginalValue of the rides in the history.
 type SubCategory struct {
 	ID          int      `json:"id"`
 	TypeID      int      `json:"typeId"`
@@ -44,6 +47,17 @@ type SubCategory struct {
 	Icon        Icon     `json:"icon"`
 }
 
+// DiscountedEstimate returns the estimate less the Discount, not below zero,
+// which is the price band of the ride value charged.
+func (s *SubCategory) DiscountedEstimate() Estimate {
+	e := s.Estimate
+	if s.Discount > 0 {
+		e.Minimum = math.Max(e.Minimum-s.Discount, 0)
+		e.Maximum = math.Max(e.Maximum-s.Discount, 0)
+	}
+	return e
+}
+
 // The categories available for the region requested.
 type Category struct {
 	ID            int           `json:"id"`
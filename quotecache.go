@@ -0,0 +1,230 @@
+package wappa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults of QuoteCache.
+const (
+	DefaultQuoteCachePrecision = 3
+	DefaultQuoteCacheTTL       = 2 * time.Minute
+)
+
+// QuoteCacheStore keeps the quotes cached by a QuoteCache.
+type QuoteCacheStore interface {
+	// Get returns the quote stored for the key and when it expires.
+	Get(key string) (q *QuoteResult, expires time.Time, ok bool)
+	// Put stores the quote for the key until it expires.
+	Put(key string, q *QuoteResult, expires time.Time)
+}
+
+// QuoteCacheStats are the counters of a QuoteCache.
+type QuoteCacheStats struct {
+	// Estimates answered from the store.
+	Hits uint64
+	// Estimates requested to the API.
+	Misses uint64
+	// Estimates answered by a concurrent identical request.
+	Shared uint64
+}
+
+// QuoteCache caches the estimates of a QuoteService. The estimates are keyed by
+// their coordinates, rounded to Precision decimal places, or place IDs, and by
+// employee, so close enough requests share the same quote. Concurrent identical
+// requests are made only once. It is safe for concurrent use.
+//
+// The quotes returned are shared, and must not be modified.
+type QuoteCache struct {
+	// Decimal places the coordinates are rounded to, 3 being about 100 meters.
+	// DefaultQuoteCachePrecision is used if zero.
+	Precision int
+	// How long the quotes are cached after their EstimatedAt, or after
+	// being requested if they have none. DefaultQuoteCacheTTL is used if <= 0.
+	TTL time.Duration
+	// Store of the quotes.
+	Store QuoteCacheStore
+
+	qs *QuoteService
+
+	mu    sync.Mutex
+	calls map[string]*quoteCall
+
+	hits, misses, shared uint64
+}
+
+type quoteCall struct {
+	done chan struct{}
+	res  *QuoteResult
+	err  error
+}
+
+// NewQuoteCache returns a QuoteCache of the service, keeping up to capacity quotes in memory for ttl.
+func NewQuoteCache(qs *QuoteService, capacity int, ttl time.Duration) *QuoteCache {
+	return &QuoteCache{
+		TTL:   ttl,
+		Store: NewLRUQuoteStore(capacity),
+		qs:    qs,
+		calls: make(map[string]*quoteCall),
+	}
+}
+
+// Estimate returns the cached quote for the filter, requesting it to the API if
+// missing or expired. Requests waiting for an identical one get its result, but
+// request the quote again if it was cancelled by the context of its caller.
+func (c *QuoteCache) Estimate(ctx context.Context, f Filter) (*QuoteResult, error) {
+	key := c.key(f)
+
+	for {
+		if q, expires, ok := c.Store.Get(key); ok && time.Now().Before(expires) {
+			atomic.AddUint64(&c.hits, 1)
+			return q, nil
+		}
+
+		c.mu.Lock()
+		call, ok := c.calls[key]
+		if !ok {
+			call = &quoteCall{done: make(chan struct{})}
+			c.calls[key] = call
+			c.mu.Unlock()
+
+			return c.fetch(ctx, key, f, call)
+		}
+		c.mu.Unlock()
+
+		atomic.AddUint64(&c.shared, 1)
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		if canceled(call.err) && ctx.Err() == nil {
+			continue
+		}
+		return call.res, call.err
+	}
+}
+
+// fetch requests the quote of the call, storing it if it isn't expired yet.
+func (c *QuoteCache) fetch(ctx context.Context, key string, f Filter, call *quoteCall) (*QuoteResult, error) {
+	atomic.AddUint64(&c.misses, 1)
+	call.res, call.err = c.qs.Estimate(ctx, f)
+	if call.err == nil {
+		if expires := c.expires(call.res); time.Now().Before(expires) {
+			c.Store.Put(key, call.res, expires)
+		}
+	}
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	c.mu.Unlock()
+	close(call.done)
+
+	return call.res, call.err
+}
+
+// canceled reports if the error is of a context canceled or past its deadline.
+func canceled(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Stats returns the counters of the cache.
+func (c *QuoteCache) Stats() QuoteCacheStats {
+	return QuoteCacheStats{
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+		Shared: atomic.LoadUint64(&c.shared),
+	}
+}
+
+func (c *QuoteCache) expires(q *QuoteResult) time.Time {
+	ttl := c.TTL
+	if ttl <= 0 {
+		ttl = DefaultQuoteCacheTTL
+	}
+
+	if q.EstimatedAt != nil {
+		return q.EstimatedAt.Add(ttl)
+	}
+	return time.Now().Add(ttl)
+}
+
+// key returns the cache key of the filter. The place IDs take
+// precedence over the coordinates, as in the API.
+func (c *QuoteCache) key(f Filter) string {
+	prec := c.Precision
+	if prec == 0 {
+		prec = DefaultQuoteCachePrecision
+	}
+
+	first := func(k string) string {
+		if v := f[k]; len(v) > 0 {
+			return strings.TrimSpace(v[0])
+		}
+		return ""
+	}
+
+	coord := func(k string) string {
+		v := first(k)
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return strconv.FormatFloat(n, 'f', prec, 64)
+		}
+		return v
+	}
+
+	origin := first("placeOrigin")
+	if origin == "" {
+		origin = coord("latOrigin") + "," + coord("lngOrigin")
+	}
+
+	destiny := first("placeDestiny")
+	if destiny == "" {
+		destiny = coord("latDest") + "," + coord("lngDest")
+	}
+
+	return fmt.Sprintf("%s|%s|%s", origin, destiny, first("employee"))
+}
+
+// LRUQuoteStore is an in-memory QuoteCacheStore keeping up to a number of
+// quotes, discarding the least recently used. It is safe for concurrent use.
+type LRUQuoteStore struct {
+	lru *lru
+}
+
+type quoteEntry struct {
+	q       *QuoteResult
+	expires time.Time
+}
+
+// NewLRUQuoteStore returns an LRUQuoteStore keeping up to capacity quotes.
+func NewLRUQuoteStore(capacity int) *LRUQuoteStore {
+	return &LRUQuoteStore{lru: newLRU(capacity)}
+}
+
+// Get implements the QuoteCacheStore interface.
+func (s *LRUQuoteStore) Get(key string) (*QuoteResult, time.Time, bool) {
+	v, ok := s.lru.get(key)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+
+	entry := v.(quoteEntry)
+	return entry.q, entry.expires, true
+}
+
+// Put implements the QuoteCacheStore interface.
+func (s *LRUQuoteStore) Put(key string, q *QuoteResult, expires time.Time) {
+	s.lru.put(key, quoteEntry{q, expires})
+}
+
+// Len returns the number of quotes in the store.
+func (s *LRUQuoteStore) Len() int {
+	return s.lru.len()
+}
@@ -0,0 +1,227 @@
+package wappa
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// quoteRequester counts the requests, answering them with testQuote
+// after the release channel, if any, is closed.
+type quoteRequester struct {
+	calls   int32
+	at      time.Time
+	err     error
+	release chan struct{}
+}
+
+func (r *quoteRequester) Request(ctx context.Context, method string, path endpoint, body, output interface{}) error {
+	atomic.AddInt32(&r.calls, 1)
+	if r.release != nil {
+		<-r.release
+	}
+	if r.err != nil {
+		return r.err
+	}
+	*output.(*QuoteResult) = *testQuote(r.at)
+	return nil
+}
+
+func TestQuoteCacheKey(t *testing.T) {
+	testCases := []struct {
+		name      string
+		precision int
+		a, b      Filter
+		wantSame  bool
+	}{
+		{
+			"rounded coordinates",
+			0,
+			Filter{"latOrigin": {"-23.55011"}, "lngOrigin": {"-46.63311"}, "latDest": {"-23.5874"}, "lngDest": {"-46.6576"}, "employee": {"7"}},
+			Filter{"latOrigin": {"-23.55019"}, "lngOrigin": {"-46.63319"}, "latDest": {"-23.5874"}, "lngDest": {"-46.6576"}, "employee": {"7"}},
+			true,
+		},
+		{
+			"precision",
+			5,
+			Filter{"latOrigin": {"-23.55051"}, "lngOrigin": {"-46.63331"}},
+			Filter{"latOrigin": {"-23.55049"}, "lngOrigin": {"-46.63329"}},
+			false,
+		},
+		{
+			"employee",
+			0,
+			Filter{"latOrigin": {"-23.5505"}, "employee": {"7"}},
+			Filter{"latOrigin": {"-23.5505"}, "employee": {"8"}},
+			false,
+		},
+		{
+			"place IDs",
+			0,
+			Filter{"placeOrigin": {"abc"}, "placeDestiny": {"def"}, "latOrigin": {"1"}},
+			Filter{"placeOrigin": {"abc"}, "placeDestiny": {"def"}, "latOrigin": {"2"}},
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &QuoteCache{Precision: tc.precision}
+			if same := c.key(tc.a) == c.key(tc.b); same != tc.wantSame {
+				t.Errorf("got keys %q and %q equal %t; want %t.", c.key(tc.a), c.key(tc.b), same, tc.wantSame)
+			}
+		})
+	}
+}
+
+func TestQuoteCacheEstimate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		at        time.Time
+		wantCalls int32
+		wantStats QuoteCacheStats
+	}{
+		{"fresh", time.Now(), 1, QuoteCacheStats{Hits: 1, Misses: 1}},
+		{"expired", time.Now().Add(-time.Hour), 2, QuoteCacheStats{Misses: 2}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &quoteRequester{at: tc.at}
+			c := NewQuoteCache(&QuoteService{req}, 10, time.Minute)
+			f := Filter{"latOrigin": {"-23.5505"}, "lngOrigin": {"-46.6333"}}
+
+			for i := 0; i < 2; i++ {
+				if q, err := c.Estimate(context.Background(), f); err != nil || len(q.Categories) != 2 {
+					t.Fatalf("got quote: %+v, %v; want testQuote, nil.", q, err)
+				}
+			}
+
+			if req.calls != tc.wantCalls {
+				t.Errorf("got requests: %d; want %d.", req.calls, tc.wantCalls)
+			}
+			if got := c.Stats(); got != tc.wantStats {
+				t.Errorf("got stats: %+v; want %+v.", got, tc.wantStats)
+			}
+		})
+	}
+}
+
+func TestQuoteCacheShared(t *testing.T) {
+	req := &quoteRequester{at: time.Now(), err: errors.New("Error"), release: make(chan struct{})}
+	c := NewQuoteCache(&QuoteService{req}, 10, time.Minute)
+	f := Filter{"placeOrigin": {"abc"}, "placeDestiny": {"def"}}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.Estimate(context.Background(), f)
+			errs <- err
+		}()
+	}
+
+	// Waits for the requests to be in flight.
+	for c.Stats().Misses+c.Stats().Shared < 5 {
+		time.Sleep(time.Millisecond)
+	}
+	close(req.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != req.err {
+			t.Errorf("got error: %v; want %v.", err, req.err)
+		}
+	}
+
+	if req.calls != 1 {
+		t.Errorf("got requests: %d; want 1.", req.calls)
+	}
+	if got := c.Stats(); got != (QuoteCacheStats{Misses: 1, Shared: 4}) {
+		t.Errorf("got stats: %+v; want 1 miss and 4 shared.", got)
+	}
+
+	// Errors are not cached.
+	if _, ok := c.Store.(*LRUQuoteStore); !ok || c.Store.(*LRUQuoteStore).Len() != 0 {
+		t.Errorf("got quotes stored after error; want none.")
+	}
+}
+
+// cancelQuoteRequester blocks the first request until its
+// context is done, answering the next ones with testQuote.
+type cancelQuoteRequester struct {
+	calls int32
+}
+
+func (r *cancelQuoteRequester) Request(ctx context.Context, method string, path endpoint, body, output interface{}) error {
+	if atomic.AddInt32(&r.calls, 1) == 1 {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	*output.(*QuoteResult) = *testQuote(time.Now())
+	return nil
+}
+
+func TestQuoteCacheSharedCanceled(t *testing.T) {
+	req := &cancelQuoteRequester{}
+	c := NewQuoteCache(&QuoteService{req}, 10, time.Minute)
+	f := Filter{"placeOrigin": {"abc"}, "placeDestiny": {"def"}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := c.Estimate(ctx, f)
+		first <- err
+	}()
+	for c.Stats().Misses < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	second := make(chan error, 1)
+	go func() {
+		_, err := c.Estimate(context.Background(), f)
+		second <- err
+	}()
+	for c.Stats().Shared < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("got error of the canceled request: %v; want %v.", err, context.Canceled)
+	}
+	if err := <-second; err != nil {
+		t.Errorf("got error of the request sharing it: %v; want nil.", err)
+	}
+
+	if got := atomic.LoadInt32(&req.calls); got != 2 {
+		t.Errorf("got requests: %d; want 2.", got)
+	}
+}
+
+func TestLRUQuoteStore(t *testing.T) {
+	s := NewLRUQuoteStore(2)
+	exp := time.Now().Add(time.Minute)
+
+	s.Put("a", &QuoteResult{}, exp)
+	s.Put("b", &QuoteResult{}, exp)
+	s.Get("a")
+	s.Put("c", &QuoteResult{}, exp)
+
+	if _, _, ok := s.Get("b"); ok {
+		t.Errorf("got least recently used quote b; want evicted.")
+	}
+	if _, got, ok := s.Get("a"); !ok || !got.Equal(exp) {
+		t.Errorf("got quote a: %t expiring %s; want kept expiring %s.", ok, got, exp)
+	}
+	if s.Len() != 2 {
+		t.Errorf("got length: %d; want 2.", s.Len())
+	}
+}
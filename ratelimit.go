@@ -0,0 +1,223 @@
+package wappa
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Lowest rate the RateLimiter reduces a limit to when learning from throttled requests.
+const minLearnedRate = 0.1
+
+// RateLimiter limits the rate of the requests made by a Client with token buckets:
+// a global one, shared by all requests, and one per endpoint.
+// Limits can be changed at any time. It is safe for concurrent use.
+type RateLimiter struct {
+	// OnWait is called with the endpoint and the time a request waited, if set.
+	OnWait func(endpoint string, d time.Duration)
+	// Learn halves the rate of a limit whenever a request under it
+	// is answered with 429 Too Many Requests.
+	Learn bool
+
+	mu        sync.Mutex
+	global    *bucket
+	endpoints map[string]*bucket
+}
+
+// NewRateLimiter returns a RateLimiter with a global limit of rate requests
+// per second, allowing bursts of burst requests. A rate <= 0 means no limit.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		global:    newBucket(rate, burst),
+		endpoints: make(map[string]*bucket),
+	}
+}
+
+// SetLimit changes the global limit.
+func (l *RateLimiter) SetLimit(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.global.setLimit(rate, burst)
+}
+
+// SetEndpointLimit sets the limit of the requests to an endpoint, as "driver/nearby"
+// or "ride". It applies to the endpoint and the ones below it (i.e. "ride" applies
+// to "ride/status"), unless they have their own limit.
+func (l *RateLimiter) SetEndpointLimit(endpoint string, rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.endpoints[endpoint]; ok {
+		b.setLimit(rate, burst)
+		return
+	}
+	l.endpoints[endpoint] = newBucket(rate, burst)
+}
+
+// Wait blocks until a request to the endpoint is allowed or the context is done,
+// returning how long it waited. It fails immediately if the context would expire
+// before the request is allowed.
+func (l *RateLimiter) Wait(ctx context.Context, endpoint string) (time.Duration, error) {
+	l.mu.Lock()
+	now := time.Now()
+	buckets := []*bucket{l.global}
+	if b := l.bucket(endpoint); b != nil {
+		buckets = append(buckets, b)
+	}
+
+	var wait time.Duration
+	for _, b := range buckets {
+		if d := b.reserve(now); d > wait {
+			wait = d
+		}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && now.Add(wait).After(deadline) {
+		cancelReservations(buckets)
+		l.mu.Unlock()
+		return 0, fmt.Errorf("wappa: rate limit wait of %s for '%s' exceeds the context deadline.", wait, endpoint)
+	}
+	l.mu.Unlock()
+
+	if wait > 0 {
+		if err := sleep(ctx, wait); err != nil {
+			l.mu.Lock()
+			cancelReservations(buckets)
+			l.mu.Unlock()
+			return 0, err
+		}
+
+		if l.OnWait != nil {
+			l.OnWait(endpoint, wait)
+		}
+	}
+
+	return wait, nil
+}
+
+// throttled is called when a request to the endpoint is answered with 429 Too Many
+// Requests, pausing its limit for the time requested by the API, if any.
+func (l *RateLimiter) throttled(endpoint string, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucket(endpoint)
+	if b == nil {
+		b = l.global
+	}
+
+	if until := time.Now().Add(retryAfter); until.After(b.pausedUntil) {
+		b.pausedUntil = until
+	}
+
+	if l.Learn && b.rate > 0 {
+		rate := b.rate / 2
+		if rate < minLearnedRate {
+			rate = minLearnedRate
+		}
+		b.setLimit(rate, int(b.burst))
+	}
+}
+
+// bucket returns the bucket of the longest endpoint prefix with a limit.
+// It must be called holding the lock.
+func (l *RateLimiter) bucket(endpoint string) *bucket {
+	for e := endpoint; e != ""; {
+		if b, ok := l.endpoints[e]; ok {
+			return b
+		}
+
+		i := strings.LastIndex(e, "/")
+		if i < 0 {
+			break
+		}
+		e = e[:i]
+	}
+	return nil
+}
+
+// limiterKey returns the endpoint used to look up the limits of a path.
+func limiterKey(path endpoint) string {
+	s := string(path)
+	if i := strings.Index(s, "?"); i >= 0 {
+		s = s[:i]
+	}
+	return strings.Trim(strings.TrimPrefix(strings.TrimPrefix(s, "/"), "api/"), "/")
+}
+
+func cancelReservations(buckets []*bucket) {
+	for _, b := range buckets {
+		b.cancel()
+	}
+}
+
+// bucket is a token bucket.
+type bucket struct {
+	// Tokens per second; no limit if <= 0.
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	// No tokens are given before it.
+	pausedUntil time.Time
+}
+
+func newBucket(rate float64, burst int) *bucket {
+	b := &bucket{last: time.Now()}
+	b.setLimit(rate, burst)
+	b.tokens = b.burst
+	return b
+}
+
+func (b *bucket) setLimit(rate float64, burst int) {
+	if burst < 1 {
+		burst = 1
+	}
+	b.advance(time.Now())
+	b.rate, b.burst = rate, float64(burst)
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// advance adds the tokens accumulated since the last update.
+func (b *bucket) advance(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+}
+
+// reserve takes a token, returning how long to wait until it is available.
+func (b *bucket) reserve(now time.Time) time.Duration {
+	var wait time.Duration
+	if b.rate > 0 {
+		b.advance(now)
+		b.tokens--
+		if b.tokens < 0 {
+			wait = time.Duration(-b.tokens / b.rate * float64(time.Second))
+		}
+	}
+
+	if d := b.pausedUntil.Sub(now); d > wait {
+		wait = d
+	}
+
+	return wait
+}
+
+// cancel gives back a reserved token.
+func (b *bucket) cancel() {
+	if b.rate > 0 {
+		b.tokens++
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+}
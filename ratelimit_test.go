@@ -0,0 +1,136 @@
+package wappa
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimiterKey(t *testing.T) {
+	testCases := []struct {
+		path endpoint
+		want string
+	}{
+		{driverEndpoint.Action(nearby).Query(url.Values{"Latitude": []string{"1"}}), "driver/nearby"},
+		{rideEndpoint, "ride"},
+		{"api/ride/status", "ride/status"},
+		{"/api/webhook/", "webhook"},
+	}
+
+	for _, tc := range testCases {
+		if got := limiterKey(tc.path); got != tc.want {
+			t.Errorf("got limiterKey(%s): '%s'; want '%s'.", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	l := NewRateLimiter(0, 0)
+	l.SetEndpointLimit("driver/nearby", 10, 1)
+
+	var waited []string
+	l.OnWait = func(endpoint string, d time.Duration) {
+		waited = append(waited, endpoint)
+	}
+
+	ctx := context.Background()
+
+	// Unlimited endpoint.
+	for i := 0; i < 3; i++ {
+		if d, err := l.Wait(ctx, "ride"); d != 0 || err != nil {
+			t.Errorf("got Wait(ride): %s, %v; want 0, nil.", d, err)
+		}
+	}
+
+	if d, err := l.Wait(ctx, "driver/nearby"); d != 0 || err != nil {
+		t.Errorf("got first Wait(driver/nearby): %s, %v; want 0, nil.", d, err)
+	}
+
+	d, err := l.Wait(ctx, "driver/nearby")
+	if err != nil {
+		t.Fatalf("got error calling Wait(driver/nearby): %s; want nil.", err.Error())
+	}
+
+	if d < 50*time.Millisecond || d > 100*time.Millisecond {
+		t.Errorf("got second Wait(driver/nearby): %s; want about 100ms.", d)
+	}
+
+	if len(waited) != 1 || waited[0] != "driver/nearby" {
+		t.Errorf("got OnWait calls: %v; want [driver/nearby].", waited)
+	}
+}
+
+func TestRateLimiterWaitDeadline(t *testing.T) {
+	l := NewRateLimiter(1, 1)
+
+	if _, err := l.Wait(context.Background(), "ride"); err != nil {
+		t.Fatalf("got error calling Wait(): %s; want nil.", err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := l.Wait(ctx, "ride"); err == nil {
+		t.Errorf("got error nil waiting past the deadline; want not nil.")
+	}
+
+	// The reservation is given back after failing.
+	l.SetLimit(1000, 1)
+	time.Sleep(5 * time.Millisecond)
+	if d, err := l.Wait(context.Background(), "ride"); d != 0 || err != nil {
+		t.Errorf("got Wait(): %s, %v; want 0, nil.", d, err)
+	}
+}
+
+func TestRateLimiterEndpointPrefix(t *testing.T) {
+	l := NewRateLimiter(0, 0)
+	l.SetEndpointLimit("ride", 1, 1)
+	l.SetEndpointLimit("ride/status", 0, 1)
+
+	if b := l.bucket("ride/cancel"); b != l.endpoints["ride"] {
+		t.Errorf("got bucket of ride/cancel different from ride.")
+	}
+
+	if b := l.bucket("ride/status"); b != l.endpoints["ride/status"] {
+		t.Errorf("got bucket of ride/status different from its own.")
+	}
+
+	if b := l.bucket("driver/nearby"); b != nil {
+		t.Errorf("got bucket of driver/nearby %+v; want nil.", b)
+	}
+}
+
+func TestClientRequestRateLimited(t *testing.T) {
+	var calls int32
+	s := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{}`))
+	})
+	defer s.Close()
+
+	l := NewRateLimiter(0, 0)
+	l.SetEndpointLimit("driver/nearby", 100, 5)
+	l.Learn = true
+
+	u, _ := url.Parse(s.URL)
+	c := NewClient(u, nil, WithRateLimiter(l), WithRetryPolicy(testRetryPolicy))
+
+	if _, err := c.Driver.Nearby(context.Background(), nil); err != nil {
+		t.Fatalf("got error calling Driver.Nearby(): %s; want nil.", err.Error())
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("got %d calls; want 2.", got)
+	}
+
+	if got := l.endpoints["driver/nearby"].rate; got != 50 {
+		t.Errorf("got learned rate: %f; want 50.", got)
+	}
+}
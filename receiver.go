@@ -0,0 +1,161 @@
+package wappa
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Defaults of WebhookHandler.
+const (
+	DefaultWebhookAuthHeader  = "Authorization"
+	DefaultWebhookMaxBodySize = 1 << 20
+)
+
+// Errors of the requests rejected by WebhookHandler.
+var (
+	ErrWebhookMethod       = errors.New("wappa: webhook method not allowed")
+	ErrWebhookUnauthorized = errors.New("wappa: webhook auth key invalid")
+	ErrWebhookTooLarge     = errors.New("wappa: webhook body too large")
+)
+
+// WebhookHandler is an http.Handler receiving the rides sent by the Webhook.
+// It should be mounted at the path of the Webhook Endpoint. See Webhook.Path.
+type WebhookHandler struct {
+	// AuthKey of the Webhook, required in every request.
+	// All requests are rejected if empty and Keys is nil.
+	AuthKey string
+	// Keys accepted besides the AuthKey, if set. See WebhookService.RotateKey.
+	Keys *WebhookKeys
+	// Header carrying the AuthKey, optionally prefixed by "Bearer ".
+	// DefaultWebhookAuthHeader is used if empty.
+	AuthHeader string
+	// Maximum size of the body in bytes. DefaultWebhookMaxBodySize is used if <= 0.
+	MaxBodySize int64
+	// OnRide is called with every ride received. If it returns an error
+	// the request is answered with 500 Internal Server Error.
+	OnRide func(ctx context.Context, r *WebhookRide) error
+	// OnError is called with the error of every rejected request, if set.
+	OnError func(r *http.Request, err error)
+	// Filter of duplicated and out of order deliveries, if set.
+	// Dropped deliveries are answered with 200 OK.
+	Filter *DeliveryFilter
+	// Events records the rides received before calling OnRide, if set. If it
+	// fails, the request is answered with 500 Internal Server Error.
+	Events RideEventStore
+}
+
+// NewWebhookHandler returns a WebhookHandler for the webhook, calling onRide with the rides
+// received. The AuthKey of the webhook is put in Keys, so it can be rotated by RotateKey.
+func NewWebhookHandler(wh *Webhook, onRide func(ctx context.Context, r *WebhookRide) error) *WebhookHandler {
+	return &WebhookHandler{Keys: NewWebhookKeys(wh.AuthKey), OnRide: onRide}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.reject(w, r, http.StatusMethodNotAllowed, ErrWebhookMethod)
+		return
+	}
+
+	if !h.authorized(r) {
+		h.reject(w, r, http.StatusUnauthorized, ErrWebhookUnauthorized)
+		return
+	}
+
+	ride, body, status, err := h.decode(r)
+	if err != nil {
+		h.reject(w, r, status, err)
+		return
+	}
+
+	ctx := r.Context()
+	if h.Filter != nil {
+		var ok bool
+		if ctx, ok = h.Filter.filter(ctx, ride); !ok {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+
+	if err := h.handle(ctx, ride, body); err != nil {
+		// Accepts the delivery again when the API retries it.
+		if h.Filter != nil {
+			h.Filter.Forget(ride)
+		}
+		h.reject(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handle records the ride and calls OnRide.
+func (h *WebhookHandler) handle(ctx context.Context, ride *WebhookRide, body []byte) error {
+	if h.Events != nil {
+		if err := h.Events.Append(ctx, NewWebhookEventRecord(ride, body)); err != nil {
+			return err
+		}
+	}
+
+	if h.OnRide != nil {
+		return h.OnRide(ctx, ride)
+	}
+
+	return nil
+}
+
+// authorized reports if the request carries the AuthKey or one of the Keys.
+func (h *WebhookHandler) authorized(r *http.Request) bool {
+	header := h.AuthHeader
+	if header == "" {
+		header = DefaultWebhookAuthHeader
+	}
+
+	key := strings.TrimPrefix(r.Header.Get(header), "Bearer ")
+
+	if h.AuthKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(h.AuthKey)) == 1 {
+		return true
+	}
+
+	return h.Keys != nil && key != "" && h.Keys.Valid(key, time.Now())
+}
+
+// decode returns the ride in the body of the request and the body,
+// or the status code the request must be rejected with.
+func (h *WebhookHandler) decode(r *http.Request) (*WebhookRide, []byte, int, error) {
+	max := h.MaxBodySize
+	if max <= 0 {
+		max = DefaultWebhookMaxBodySize
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(r.Body, max+1))
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, err
+	}
+
+	if int64(len(b)) > max {
+		return nil, nil, http.StatusRequestEntityTooLarge, ErrWebhookTooLarge
+	}
+
+	ride := &WebhookRide{}
+	if err := json.Unmarshal(b, ride); err != nil {
+		return nil, nil, http.StatusBadRequest, err
+	}
+
+	return ride, b, 0, nil
+}
+
+func (h *WebhookHandler) reject(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if h.OnError != nil {
+		h.OnError(r, err)
+	}
+	http.Error(w, http.StatusText(status), status)
+}
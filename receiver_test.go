@@ -0,0 +1,117 @@
+package wappa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testWebhookPayload = `{"code": 1, "rideId": 10, "companyId": 2, "status": "on-ride", "timeToOrigin": "00:05:00", "externalId": "ext"}`
+
+func TestWebhookHandler(t *testing.T) {
+	testCases := []struct {
+		name       string
+		method     string
+		authKey    string
+		body       string
+		onRideErr  error
+		wantStatus int
+		wantRide   bool
+		wantErr    error
+	}{
+		{"valid", http.MethodPost, "auth-key", testWebhookPayload, nil, http.StatusOK, true, nil},
+		{"bearer", http.MethodPost, "Bearer auth-key", testWebhookPayload, nil, http.StatusOK, true, nil},
+		{"wrong method", http.MethodGet, "auth-key", "", nil, http.StatusMethodNotAllowed, false, ErrWebhookMethod},
+		{"missing key", http.MethodPost, "", testWebhookPayload, nil, http.StatusUnauthorized, false, ErrWebhookUnauthorized},
+		{"wrong key", http.MethodPost, "other-key", testWebhookPayload, nil, http.StatusUnauthorized, false, ErrWebhookUnauthorized},
+		{"malformed", http.MethodPost, "auth-key", `{"rideId": "abc"}`, nil, http.StatusBadRequest, false, nil},
+		{"too large", http.MethodPost, "auth-key", `{"externalId": "` + strings.Repeat("a", 200) + `"}`, nil, http.StatusRequestEntityTooLarge, false, ErrWebhookTooLarge},
+		{"callback error", http.MethodPost, "auth-key", testWebhookPayload, errors.New("failed"), http.StatusInternalServerError, true, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got *WebhookRide
+			var gotErr error
+
+			h := NewWebhookHandler(&Webhook{AuthKey: "auth-key"}, func(ctx context.Context, r *WebhookRide) error {
+				got = r
+				return tc.onRideErr
+			})
+			h.MaxBodySize = 200
+			h.OnError = func(r *http.Request, err error) {
+				gotErr = err
+			}
+
+			req := httptest.NewRequest(tc.method, "/webhook", strings.NewReader(tc.body))
+			if tc.authKey != "" {
+				req.Header.Set("Authorization", tc.authKey)
+			}
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("got status code: %d; want %d.", rec.Code, tc.wantStatus)
+			}
+
+			if (got != nil) != tc.wantRide {
+				t.Errorf("got ride %+v; want ride %t.", got, tc.wantRide)
+			}
+
+			if tc.wantErr != nil && gotErr != tc.wantErr {
+				t.Errorf("got error: %v; want %v.", gotErr, tc.wantErr)
+			}
+
+			if tc.wantStatus != http.StatusOK && gotErr == nil {
+				t.Errorf("got OnError not called; want it called.")
+			}
+		})
+	}
+}
+
+func TestWebhookHandlerDecode(t *testing.T) {
+	var got *WebhookRide
+	h := NewWebhookHandler(&Webhook{AuthKey: "auth-key"}, func(ctx context.Context, r *WebhookRide) error {
+		got = r
+		return nil
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle((&Webhook{Endpoint: "path/for/testing"}).Path(), h)
+
+	req := httptest.NewRequest(http.MethodPost, "/path/for/testing", strings.NewReader(testWebhookPayload))
+	req.Header.Set("Authorization", "auth-key")
+	rec := httptest.NewRecorder()
+
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status code: %d; want %d.", rec.Code, http.StatusOK)
+	}
+
+	if got.RideID != 10 || got.CompanyID != 2 || got.Status != RideStatusInProgress || got.ExternalID != "ext" {
+		t.Errorf("got ride: %+v; want decoded payload.", got)
+	}
+
+	if got.TimeToOrigin.Duration != 5*time.Minute {
+		t.Errorf("got TimeToOrigin: %s; want 5m.", got.TimeToOrigin)
+	}
+}
+
+func TestWebhookHandlerEmptyAuthKey(t *testing.T) {
+	h := &WebhookHandler{}
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(testWebhookPayload))
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("got status code: %d; want %d.", rec.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,211 @@
+package wappa
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Defaults of RefreshOptions.
+const (
+	DefaultRefreshMargin     = time.Minute
+	DefaultRefreshMinBackoff = time.Second
+	DefaultRefreshMaxBackoff = time.Minute
+)
+
+// RefreshOptions configures a RefreshingTokenSource.
+type RefreshOptions struct {
+	// How long before the expiration (the JWT exp claim) a token is refreshed.
+	Margin time.Duration
+	// Wait before fetching a token again after the first failure.
+	// It doubles on each consecutive failure.
+	MinBackoff time.Duration
+	// Upper limit of the wait between failed fetches.
+	MaxBackoff time.Duration
+	// Called with the error of every failed fetch or cache write, if set.
+	OnError func(err error)
+	// Cache of the tokens, checked before fetching a new token from the source.
+	// Corrupt or expired entries are ignored and replaced, as are the ones
+	// failing the verification of a *TokenSource. See TokenSource.VerifyKey.
+	Cache TokenCache
+	// Key of the tokens in the Cache. See TokenCacheKey.
+	CacheKey string
+}
+
+// RefreshingTokenSource caches the token of a source and refreshes it before
+// it expires. Concurrent calls share a single fetch, and fetches are not
+// attempted again until a backoff has passed after a failure.
+// It is safe for concurrent use.
+type RefreshingTokenSource struct {
+	src  oauth2.TokenSource
+	opts RefreshOptions
+
+	mu     sync.Mutex
+	token  *oauth2.Token
+	claims *Claims
+	// Closed when the fetch in progress finishes, nil if none.
+	fetching chan struct{}
+	// Error of the last fetch.
+	err      error
+	failures int
+	retryAt  time.Time
+}
+
+// NewRefreshingTokenSource returns a RefreshingTokenSource for the tokens of src,
+// which is usually a *TokenSource. A nil opts uses the defaults.
+func NewRefreshingTokenSource(src oauth2.TokenSource, opts *RefreshOptions) *RefreshingTokenSource {
+	ts := &RefreshingTokenSource{src: src}
+	if opts != nil {
+		ts.opts = *opts
+	}
+
+	if ts.opts.Margin <= 0 {
+		ts.opts.Margin = DefaultRefreshMargin
+	}
+	if ts.opts.MinBackoff <= 0 {
+		ts.opts.MinBackoff = DefaultRefreshMinBackoff
+	}
+	if ts.opts.MaxBackoff <= 0 {
+		ts.opts.MaxBackoff = DefaultRefreshMaxBackoff
+	}
+
+	return ts
+}
+
+// Token implements the oauth2.TokenSource interface.
+//
+// A token within the refresh margin is still returned while
+// a new one is fetched in background.
+func (ts *RefreshingTokenSource) Token() (*oauth2.Token, error) {
+	tk, _, err := ts.current()
+	return tk, err
+}
+
+// Claims returns the claims of the current token, fetching it if needed.
+func (ts *RefreshingTokenSource) Claims() (*Claims, error) {
+	_, claims, err := ts.current()
+	return claims, err
+}
+
+func (ts *RefreshingTokenSource) current() (*oauth2.Token, *Claims, error) {
+	ts.mu.Lock()
+
+	now := time.Now()
+	if ts.fresh(ts.token, now) {
+		defer ts.mu.Unlock()
+		return ts.token, ts.claims, nil
+	}
+
+	valid := ts.token != nil && ts.token.Expiry.After(now)
+
+	if now.Before(ts.retryAt) {
+		defer ts.mu.Unlock()
+		if valid {
+			return ts.token, ts.claims, nil
+		}
+		return nil, nil, ts.err
+	}
+
+	if ts.fetching == nil {
+		ts.fetching = make(chan struct{})
+		go ts.refresh(ts.fetching)
+	}
+
+	if valid {
+		defer ts.mu.Unlock()
+		return ts.token, ts.claims, nil
+	}
+
+	done := ts.fetching
+	ts.mu.Unlock()
+
+	<-done
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.err != nil {
+		return nil, nil, ts.err
+	}
+	return ts.token, ts.claims, nil
+}
+
+// refresh fetches a new token from the source, closing done when finished.
+func (ts *RefreshingTokenSource) refresh(done chan struct{}) {
+	tk, claims, err := ts.fetch()
+
+	if err != nil && ts.opts.OnError != nil {
+		ts.opts.OnError(err)
+	}
+
+	ts.mu.Lock()
+	if err != nil {
+		ts.failures++
+		ts.retryAt = time.Now().Add(ts.backoff())
+	} else {
+		ts.token, ts.claims = tk, claims
+		ts.failures = 0
+		ts.retryAt = time.Time{}
+	}
+	ts.err = err
+	ts.fetching = nil
+	close(done)
+	ts.mu.Unlock()
+}
+
+// fetch returns a fresh token from the cache or, if there is none, from the source.
+func (ts *RefreshingTokenSource) fetch() (*oauth2.Token, *Claims, error) {
+	cache := ts.opts.Cache
+	if cache != nil {
+		if tk, err := cache.Get(ts.opts.CacheKey); err == nil && ts.fresh(tk, time.Now()) {
+			if claims, err := ts.verify(tk); err == nil {
+				return tk, claims, nil
+			}
+		}
+	}
+
+	tk, err := ts.src.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	claims, err := ParseClaims(tk.AccessToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cache != nil {
+		if err := cache.Put(ts.opts.CacheKey, tk); err != nil && ts.opts.OnError != nil {
+			ts.opts.OnError(err)
+		}
+	}
+
+	return tk, claims, nil
+}
+
+// verify returns the claims of a token not issued by the source, verified
+// as the source verifies its own tokens, if it does.
+func (ts *RefreshingTokenSource) verify(tk *oauth2.Token) (*Claims, error) {
+	if v, ok := ts.src.(tokenVerifier); ok {
+		return v.verify(tk.AccessToken)
+	}
+	return ParseClaims(tk.AccessToken)
+}
+
+// fresh reports if the token doesn't need to be refreshed yet.
+func (ts *RefreshingTokenSource) fresh(tk *oauth2.Token, now time.Time) bool {
+	return tk != nil && (tk.Expiry.IsZero() || tk.Expiry.Sub(now) > ts.opts.Margin)
+}
+
+// backoff returns the wait after the current number of consecutive failures.
+func (ts *RefreshingTokenSource) backoff() time.Duration {
+	d := ts.opts.MinBackoff
+	for i := 1; i < ts.failures && d < ts.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > ts.opts.MaxBackoff {
+		d = ts.opts.MaxBackoff
+	}
+	return d
+}
@@ -0,0 +1,142 @@
+package wappa
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"golang.org/x/oauth2"
+)
+
+// testTokenSource returns JWTs expiring after exp, or err if set.
+type testTokenSource struct {
+	calls int32
+	exp   time.Duration
+	delay time.Duration
+	err   error
+}
+
+func (s *testTokenSource) Token() (*oauth2.Token, error) {
+	atomic.AddInt32(&s.calls, 1)
+	time.Sleep(s.delay)
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	exp := time.Now().Add(s.exp)
+	claims := &jwt.StandardClaims{ExpiresAt: exp.Unix(), Subject: "test"}
+	ss, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("AllYourBase"))
+
+	return &oauth2.Token{AccessToken: ss, Expiry: exp}, nil
+}
+
+func TestRefreshingTokenSourceSingleFlight(t *testing.T) {
+	src := &testTokenSource{exp: time.Hour, delay: 20 * time.Millisecond}
+	ts := NewRefreshingTokenSource(src, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ts.Token(); err != nil {
+				t.Errorf("got error calling Token(): %s; want nil.", err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&src.calls); got != 1 {
+		t.Errorf("got %d fetches; want 1.", got)
+	}
+
+	claims, err := ts.Claims()
+	if err != nil {
+		t.Fatalf("got error calling Claims(): %s; want nil.", err.Error())
+	}
+
+	if sub := claims.Subject; sub != "test" {
+		t.Errorf("got Claims.Subject: %s; want 'test'.", sub)
+	}
+}
+
+func TestRefreshingTokenSourceMargin(t *testing.T) {
+	src := &testTokenSource{exp: 30 * time.Second}
+	ts := NewRefreshingTokenSource(src, &RefreshOptions{Margin: time.Minute})
+
+	first, err := ts.Token()
+	if err != nil {
+		t.Fatalf("got error calling Token(): %s; want nil.", err.Error())
+	}
+
+	// Within the margin, the current token is returned and a refresh starts.
+	second, err := ts.Token()
+	if err != nil {
+		t.Fatalf("got error calling Token(): %s; want nil.", err.Error())
+	}
+
+	if second != first {
+		t.Errorf("got a different token while refreshing; want the current one.")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&src.calls) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if got := atomic.LoadInt32(&src.calls); got != 2 {
+		t.Errorf("got %d fetches; want 2.", got)
+	}
+}
+
+func TestRefreshingTokenSourceBackoff(t *testing.T) {
+	wantErr := errors.New("invalid credentials")
+	src := &testTokenSource{err: wantErr}
+
+	var reported []error
+	ts := NewRefreshingTokenSource(src, &RefreshOptions{
+		MinBackoff: time.Hour,
+		OnError: func(err error) {
+			reported = append(reported, err)
+		},
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := ts.Token(); err != wantErr {
+			t.Errorf("got error calling Token(): %v; want %v.", err, wantErr)
+		}
+	}
+
+	if got := atomic.LoadInt32(&src.calls); got != 1 {
+		t.Errorf("got %d fetches; want 1.", got)
+	}
+
+	if len(reported) != 1 || reported[0] != wantErr {
+		t.Errorf("got reported errors: %v; want [%v].", reported, wantErr)
+	}
+}
+
+func TestRefreshingTokenSourceBackoffDuration(t *testing.T) {
+	ts := NewRefreshingTokenSource(nil, &RefreshOptions{MinBackoff: time.Second, MaxBackoff: 5 * time.Second})
+
+	testCases := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		ts.failures = tc.failures
+		if got := ts.backoff(); got != tc.want {
+			t.Errorf("got backoff after %d failures: %s; want %s.", tc.failures, got, tc.want)
+		}
+	}
+}
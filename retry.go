@@ -0,0 +1,166 @@
+package wappa
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryClassifier decides if a failed attempt of a request should be retried.
+type RetryClassifier interface {
+	// Retry reports if the request should be attempted again given the response
+	// or the error of the last attempt. The response body is already consumed.
+	Retry(req *http.Request, res *http.Response, err error) bool
+}
+
+// RetryClassifierFunc is an adapter to allow the use of ordinary functions as RetryClassifier.
+type RetryClassifierFunc func(req *http.Request, res *http.Response, err error) bool
+
+// Retry calls f(req, res, err).
+func (f RetryClassifierFunc) Retry(req *http.Request, res *http.Response, err error) bool {
+	return f(req, res, err)
+}
+
+// DefaultRetryClassifier retries requests failed by connection errors or
+// answered with statuses indicating a transient failure of the API.
+var DefaultRetryClassifier RetryClassifier = RetryClassifierFunc(func(req *http.Request, res *http.Response, err error) bool {
+	if err != nil {
+		// Cancelled or expired by the caller.
+		return req.Context().Err() == nil
+	}
+
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+})
+
+// RetryPolicy configures how requests are retried by the Client.
+//
+// Only GET requests are retried, unless the request context
+// was created with AllowRetry.
+type RetryPolicy struct {
+	// Maximum number of attempts, including the first one.
+	MaxAttempts int
+	// Backoff before the first retry. It doubles on each attempt.
+	MinBackoff time.Duration
+	// Upper limit of the backoff between attempts. Requests whose response asks,
+	// through Retry-After, for a longer wait are not retried, returning the response.
+	MaxBackoff time.Duration
+	// Decides which failures are retried. DefaultRetryClassifier is used if nil.
+	Classifier RetryClassifier
+}
+
+// DefaultRetryPolicy is the RetryPolicy copied by the clients returned by NewClient.
+var DefaultRetryPolicy = &RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  200 * time.Millisecond,
+	MaxBackoff:  2 * time.Second,
+}
+
+type retryKey struct{}
+
+// AllowRetry returns a copy of ctx that marks the requests made with it as safe
+// to be retried, regardless of their method. Use it for POSTs known to be idempotent.
+func AllowRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryKey{}, true)
+}
+
+func retryAllowed(req *http.Request) bool {
+	if req.Method == http.MethodGet {
+		return true
+	}
+	allowed, _ := req.Context().Value(retryKey{}).(bool)
+	return allowed
+}
+
+// next reports if the request must be attempted again
+// and how long to wait before doing it.
+func (p *RetryPolicy) next(req *http.Request, res *http.Response, err error, attempt int) (time.Duration, bool) {
+	if p == nil || attempt >= p.MaxAttempts || !retryAllowed(req) {
+		return 0, false
+	}
+
+	classifier := p.Classifier
+	if classifier == nil {
+		classifier = DefaultRetryClassifier
+	}
+	if !classifier.Retry(req, res, err) {
+		return 0, false
+	}
+
+	wait, ok := retryAfter(res)
+	if !ok {
+		wait = p.backoff(attempt)
+	} else if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		// Retrying sooner than requested would likely fail again.
+		return 0, false
+	}
+
+	// Not worth waiting if the context expires before the next attempt.
+	if deadline, ok := req.Context().Deadline(); ok && time.Now().Add(wait).After(deadline) {
+		return 0, false
+	}
+
+	return wait, true
+}
+
+// backoff returns the exponential backoff for the attempt, with jitter.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+
+	if half := int64(d / 2); half > 0 {
+		d = time.Duration(half + rand.Int63n(half))
+	}
+
+	return d
+}
+
+// retryAfter returns the wait time requested by the server through the Retry-After header.
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	if res == nil {
+		return 0, false
+	}
+
+	v := res.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+
+	return 0, false
+}
+
+// sleep waits for d or until the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
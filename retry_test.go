@@ -0,0 +1,211 @@
+package wappa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testRetryPolicy = &RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  time.Millisecond,
+	MaxBackoff:  5 * time.Millisecond,
+}
+
+func TestClientRequestRetry(t *testing.T) {
+	testCases := []struct {
+		name         string
+		ctx          context.Context
+		method       string
+		policy       *RetryPolicy
+		failures     int32
+		status       int
+		wantAttempts int32
+		wantErr      bool
+	}{
+		{"GET recovers", context.Background(), http.MethodGet, testRetryPolicy, 2, http.StatusBadGateway, 3, false},
+		{"GET exhausts attempts", context.Background(), http.MethodGet, testRetryPolicy, 5, http.StatusServiceUnavailable, 3, true},
+		{"GET not retryable status", context.Background(), http.MethodGet, testRetryPolicy, 1, http.StatusNotFound, 1, true},
+		{"POST not retried", context.Background(), http.MethodPost, testRetryPolicy, 1, http.StatusBadGateway, 1, true},
+		{"POST allowed", AllowRetry(context.Background()), http.MethodPost, testRetryPolicy, 1, http.StatusBadGateway, 2, false},
+		{"nil policy", context.Background(), http.MethodGet, nil, 1, http.StatusBadGateway, 1, true},
+		{
+			"custom classifier",
+			context.Background(),
+			http.MethodGet,
+			&RetryPolicy{
+				MaxAttempts: 3,
+				Classifier: RetryClassifierFunc(func(req *http.Request, res *http.Response, err error) bool {
+					return res != nil && res.StatusCode == http.StatusNotFound
+				}),
+			},
+			1,
+			http.StatusNotFound,
+			2,
+			false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var attempts int32
+			s := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&attempts, 1) <= tc.failures {
+					w.WriteHeader(tc.status)
+					return
+				}
+				w.Write([]byte(`{"success": true}`))
+			})
+			defer s.Close()
+
+			u, _ := url.Parse(s.URL)
+			c := NewClient(u, nil)
+			c.Retry = tc.policy
+
+			err := c.Request(tc.ctx, tc.method, "", nil, &Result{})
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("got error: %v; want error %t.", err, tc.wantErr)
+			}
+
+			if got := atomic.LoadInt32(&attempts); got != tc.wantAttempts {
+				t.Errorf("got %d attempts; want %d.", got, tc.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestClientRequestRetryBody(t *testing.T) {
+	var attempts int32
+	s := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		d := &dummy{}
+		if err := json.NewDecoder(r.Body).Decode(d); err != nil || d.Name != "Testing" {
+			t.Errorf("got body %+v (%v); want name 'Testing'.", d, err)
+		}
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{}`))
+	})
+	defer s.Close()
+
+	u, _ := url.Parse(s.URL)
+	c := NewClient(u, nil)
+	c.Retry = testRetryPolicy
+
+	if err := c.Request(AllowRetry(context.Background()), http.MethodPost, "", &dummy{"Testing"}, &dummy{}); err != nil {
+		t.Fatalf("got error calling Client.Request(): %s; want nil.", err.Error())
+	}
+}
+
+func TestClientRequestRetryDeadline(t *testing.T) {
+	var attempts int32
+	s := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.Header().Set("Retry-After", "10")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	defer s.Close()
+
+	u, _ := url.Parse(s.URL)
+	c := NewClient(u, nil)
+	c.Retry = &RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Minute}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err := c.Request(ctx, http.MethodGet, "", nil, &Result{})
+	if !errors.Is(err, ErrServer) {
+		t.Errorf("got error: %v; want %v.", err, ErrServer)
+	}
+
+	if got := atomic.LoadInt32(&attempts); got != 1 {
+		t.Errorf("got %d attempts; want 1.", got)
+	}
+}
+
+func TestClientRequestRetryAfterMaxBackoff(t *testing.T) {
+	var attempts int32
+	s := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	defer s.Close()
+
+	u, _ := url.Parse(s.URL)
+	c := NewClient(u, nil)
+	c.Retry = testRetryPolicy
+
+	start := time.Now()
+	err := c.Request(context.Background(), http.MethodGet, "", nil, &Result{})
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("got error: %v; want %v.", err, ErrRateLimited)
+	}
+
+	if got := atomic.LoadInt32(&attempts); got != 1 || time.Since(start) > time.Second {
+		t.Errorf("got %d attempts in %s; want 1, without waiting.", got, time.Since(start))
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	testCases := []struct {
+		header string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"abc", 0, false},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+	}
+
+	for _, tc := range testCases {
+		res := &http.Response{Header: http.Header{}}
+		if tc.header != "" {
+			res.Header.Set("Retry-After", tc.header)
+		}
+
+		got, ok := retryAfter(res)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("got retryAfter(%s): %s, %t; want %s, %t.", tc.header, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := &RetryPolicy{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	testCases := []struct {
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, 100 * time.Millisecond, 200 * time.Millisecond},
+		{3, 200 * time.Millisecond, 400 * time.Millisecond},
+		{10, 500 * time.Millisecond, time.Second},
+	}
+
+	for _, tc := range testCases {
+		if got := p.backoff(tc.attempt); got < tc.min || got > tc.max {
+			t.Errorf("got backoff(%d): %s; want between %s and %s.", tc.attempt, got, tc.min, tc.max)
+		}
+	}
+}
+
+func TestNewClientRetryPolicy(t *testing.T) {
+	a := NewClient(&url.URL{}, nil)
+	b := NewClient(&url.URL{}, nil)
+
+	a.Retry.MaxAttempts = 5
+
+	if b.Retry.MaxAttempts != DefaultRetryPolicy.MaxAttempts || DefaultRetryPolicy.MaxAttempts == 5 {
+		t.Errorf("got retry policy shared between clients; want a copy of DefaultRetryPolicy.")
+	}
+}
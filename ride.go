@@ -7,16 +7,19 @@ import (
 
 const rideEndpoint endpoint = `ride`
 
+// RideStatus is the status of a ride in its lifecycle.
+type RideStatus string
+
 // Ride statuses.
 const (
-	RideStatusSearchingForDriver = "searching-for-driver"
-	RideStatusDriverNotFound     = "driver-not-found"
-	RideStatusCancelled          = "ride-cancelled"
-	RideStatusDriverFound        = "driver-found"
-	RideStatusWaitingForDriver   = "waiting-for-driver"
-	RideStatusInProgress         = "on-ride"
-	RideStatusPaid               = "ride-paid"
-	RideStatusCompleted          = "ride-completed"
+	RideStatusSearchingForDriver RideStatus = "searching-for-driver"
+	RideStatusDriverNotFound     RideStatus = "driver-not-found"
+	RideStatusCancelled          RideStatus = "ride-cancelled"
+	RideStatusDriverFound        RideStatus = "driver-found"
+	RideStatusWaitingForDriver   RideStatus = "waiting-for-driver"
+	RideStatusInProgress         RideStatus = "on-ride"
+	RideStatusPaid               RideStatus = "ride-paid"
+	RideStatusCompleted          RideStatus = "ride-completed"
 )
 
 // Cancelled By
@@ -99,7 +102,7 @@ type RideInfo struct {
 	//  - on-ride
 	//  - ride-paid
 	//  - ride-completed
-	Status         string     `json:"status"`
+	Status         RideStatus `json:"status"`
 	DriverLocation Location   `json:"driverLocation"`
 	ToOrigin       TravelInfo `json:"toOrigin"`
 	ToDestiny      TravelInfo `json:"toDestiny"`
@@ -168,8 +171,13 @@ func (rs *RideService) Read(ctx context.Context, f Filter) (*RideResult, error)
 	return r, nil
 }
 
-// Create creates a new ride in the API.
+// Create creates a new ride in the API. The ride is validated before
+// being sent, returning a *ValidationError if it is invalid. See Ride.Validate.
 func (rs *RideService) Create(ctx context.Context, r *Ride) (*RideResult, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
 	res := &RideResult{}
 
 	if err := rs.client.Request(ctx, http.MethodPost, rideEndpoint, r, res); err != nil {
@@ -8,6 +8,9 @@ import (
 	"testing"
 )
 
+// testRide is a valid ride to be created.
+var testRide = Ride{EmployeeID: 1, LatOrigin: -23.5505, LngOrigin: -46.6333, LatDestiny: -23.5874, LngDestiny: -46.6576}
+
 func TestRide(t *testing.T) {
 	testCases := []testTable{
 		{
@@ -28,13 +31,13 @@ func TestRide(t *testing.T) {
 		{
 			"Create()",
 			func(ctx context.Context, req requester) (resp interface{}, err error) {
-				resp, err = (&RideService{req}).Create(ctx, &Ride{LatOrigin: 3.14})
+				resp, err = (&RideService{req}).Create(ctx, &testRide)
 				return
 			},
 			context.Background(),
 			http.MethodPost,
 			rideEndpoint,
-			&Ride{LatOrigin: 3.14},
+			&testRide,
 			&RideResult{
 				Result: Result{Success: true},
 				ID:     2,
@@ -118,7 +121,7 @@ func TestRideError(t *testing.T) {
 		{
 			"Create()",
 			func(req requester) error {
-				_, err := (&RideService{req}).Create(context.Background(), nil)
+				_, err := (&RideService{req}).Create(context.Background(), &testRide)
 				return err
 			},
 			errors.New("Error"),
@@ -0,0 +1,83 @@
+package wappa
+
+import "fmt"
+
+// Position of the statuses in the ride lifecycle. Terminal
+// statuses come last, as a ride doesn't leave them.
+var rideStatusRank = map[RideStatus]int{
+	RideStatusSearchingForDriver: 1,
+	RideStatusDriverFound:        2,
+	RideStatusWaitingForDriver:   3,
+	RideStatusInProgress:         4,
+	RideStatusCompleted:          5,
+	RideStatusPaid:               6,
+	RideStatusDriverNotFound:     7,
+	RideStatusCancelled:          7,
+}
+
+// Statuses a ride may move to from each status.
+var rideStatusTransitions = map[RideStatus][]RideStatus{
+	RideStatusSearchingForDriver: {RideStatusDriverFound, RideStatusDriverNotFound, RideStatusCancelled},
+	RideStatusDriverFound:        {RideStatusWaitingForDriver, RideStatusInProgress, RideStatusCancelled},
+	RideStatusWaitingForDriver:   {RideStatusInProgress, RideStatusCancelled},
+	RideStatusInProgress:         {RideStatusCompleted, RideStatusPaid, RideStatusCancelled},
+	RideStatusCompleted:          {RideStatusPaid},
+}
+
+// ParseRideStatus returns the RideStatus of s, or an error if it is unknown.
+func ParseRideStatus(s string) (RideStatus, error) {
+	st := RideStatus(s)
+	if !st.Valid() {
+		return "", fmt.Errorf("wappa: unknown ride status %q", s)
+	}
+	return st, nil
+}
+
+// String implements the fmt.Stringer interface.
+func (s RideStatus) String() string {
+	return string(s)
+}
+
+// Valid reports if s is a known status.
+func (s RideStatus) Valid() bool {
+	_, ok := rideStatusRank[s]
+	return ok
+}
+
+// Rank returns the position of s in the ride lifecycle, starting at 1, so
+// a status with a lower rank comes before. The cancelled and driver not
+// found statuses share the last rank. Unknown statuses rank 0.
+func (s RideStatus) Rank() int {
+	return rideStatusRank[s]
+}
+
+// IsTerminal reports if the ride doesn't leave the status, i.e. it is
+// cancelled, without a driver found or paid. A completed ride is not
+// terminal, as it may still be paid.
+func (s RideStatus) IsTerminal() bool {
+	return s.Valid() && len(rideStatusTransitions[s]) == 0
+}
+
+// CanTransitionTo reports if a ride may move from s to next.
+func (s RideStatus) CanTransitionTo(next RideStatus) bool {
+	for _, st := range rideStatusTransitions[s] {
+		if st == next {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (s RideStatus) MarshalText() ([]byte, error) {
+	return []byte(s), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+//
+// Any status is decoded as is, so the responses with statuses added to
+// the API are still read. Unknown statuses are told apart by Valid.
+func (s *RideStatus) UnmarshalText(text []byte) error {
+	*s = RideStatus(text)
+	return nil
+}
@@ -0,0 +1,100 @@
+package wappa
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRideStatusLifecycle(t *testing.T) {
+	testCases := []struct {
+		from, to       RideStatus
+		wantTerminal   bool
+		wantTransition bool
+	}{
+		{RideStatusSearchingForDriver, RideStatusDriverFound, false, true},
+		{RideStatusSearchingForDriver, RideStatusInProgress, false, false},
+		{RideStatusDriverFound, RideStatusWaitingForDriver, false, true},
+		{RideStatusWaitingForDriver, RideStatusDriverFound, false, false},
+		{RideStatusInProgress, RideStatusCompleted, false, true},
+		{RideStatusCompleted, RideStatusPaid, false, true},
+		{RideStatusPaid, RideStatusCompleted, true, false},
+		{RideStatusCancelled, RideStatusSearchingForDriver, true, false},
+		{RideStatusDriverNotFound, RideStatusDriverFound, true, false},
+		{RideStatus("unknown"), RideStatusDriverFound, false, false},
+	}
+
+	for _, tc := range testCases {
+		if got := tc.from.IsTerminal(); got != tc.wantTerminal {
+			t.Errorf("got %s.IsTerminal(): %t; want %t.", tc.from, got, tc.wantTerminal)
+		}
+
+		if got := tc.from.CanTransitionTo(tc.to); got != tc.wantTransition {
+			t.Errorf("got %s.CanTransitionTo(%s): %t; want %t.", tc.from, tc.to, got, tc.wantTransition)
+		}
+	}
+}
+
+func TestRideStatusRank(t *testing.T) {
+	order := []RideStatus{
+		RideStatusSearchingForDriver,
+		RideStatusDriverFound,
+		RideStatusWaitingForDriver,
+		RideStatusInProgress,
+		RideStatusCompleted,
+		RideStatusPaid,
+		RideStatusCancelled,
+	}
+
+	for i := 1; i < len(order); i++ {
+		if order[i-1].Rank() >= order[i].Rank() {
+			t.Errorf("got %s ranked %d, not before %s ranked %d.", order[i-1], order[i-1].Rank(), order[i], order[i].Rank())
+		}
+	}
+
+	if got := RideStatus("unknown").Rank(); got != 0 {
+		t.Errorf("got rank of unknown status: %d; want 0.", got)
+	}
+}
+
+func TestRideStatusText(t *testing.T) {
+	testCases := []struct {
+		body      string
+		want      RideStatus
+		wantValid bool
+	}{
+		{`{"status": "on-ride"}`, RideStatusInProgress, true},
+		{`{"status": ""}`, "", false},
+		{`{}`, "", false},
+		{`{"status": "ride-scheduled"}`, "ride-scheduled", false},
+	}
+
+	for _, tc := range testCases {
+		var got RideInfo
+		if err := json.Unmarshal([]byte(tc.body), &got); err != nil {
+			t.Fatalf("got error unmarshalling %s: %s; want nil.", tc.body, err.Error())
+		}
+
+		if got.Status != tc.want || got.Status.Valid() != tc.wantValid {
+			t.Errorf("got status from %s: '%s', valid %t; want '%s', valid %t.", tc.body, got.Status, got.Status.Valid(), tc.want, tc.wantValid)
+		}
+	}
+
+	b, err := json.Marshal(map[string]RideStatus{"status": RideStatusPaid})
+	if err != nil {
+		t.Fatalf("got error marshalling: %s; want nil.", err.Error())
+	}
+
+	if want := `{"status":"ride-paid"}`; string(b) != want {
+		t.Errorf("got marshalled status: %s; want %s.", b, want)
+	}
+}
+
+func TestParseRideStatus(t *testing.T) {
+	if got, err := ParseRideStatus("ride-paid"); err != nil || got != RideStatusPaid {
+		t.Errorf("got ParseRideStatus(ride-paid): %s, %v; want %s, nil.", got, err, RideStatusPaid)
+	}
+
+	if _, err := ParseRideStatus("paid"); err == nil {
+		t.Errorf("got nil error parsing unknown status; want error.")
+	}
+}
@@ -0,0 +1,252 @@
+package wappa
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Default time the old key is accepted after a rotation.
+const DefaultKeyRotationGrace = 10 * time.Minute
+
+// Errors of RotateKey.
+var (
+	ErrNoWebhook       = errors.New("wappa: no webhook registered")
+	ErrKeyNotPersisted = errors.New("wappa: webhook auth key not updated in the API")
+)
+
+// WebhookKeys is a set of AuthKeys accepted by a WebhookHandler, each valid
+// until its expiry. It is safe for concurrent use.
+type WebhookKeys struct {
+	mu   sync.RWMutex
+	keys map[string]time.Time
+}
+
+// NewWebhookKeys returns a WebhookKeys with the keys, without expiry.
+func NewWebhookKeys(keys ...string) *WebhookKeys {
+	k := &WebhookKeys{keys: make(map[string]time.Time)}
+	for _, key := range keys {
+		k.Add(key, time.Time{})
+	}
+	return k
+}
+
+// Add adds the key, valid until expires, or indefinitely if zero.
+// Empty keys are ignored.
+func (k *WebhookKeys) Add(key string, expires time.Time) {
+	if key == "" {
+		return
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.keys[key] = expires
+}
+
+// Remove removes the key.
+func (k *WebhookKeys) Remove(key string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	delete(k.keys, key)
+}
+
+// Valid reports if the key is in the set and not expired at now.
+func (k *WebhookKeys) Valid(key string, now time.Time) bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	valid := false
+	// Compares all the keys, not to leak which one matched.
+	for candidate, expires := range k.keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(candidate)) == 1 && (expires.IsZero() || now.Before(expires)) {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// has reports if the key is in the set, expired or not.
+func (k *WebhookKeys) has(key string) bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	_, ok := k.keys[key]
+	return ok
+}
+
+// permanent returns the keys without expiry, but except.
+func (k *WebhookKeys) permanent(except string) []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	var keys []string
+	for key, expires := range k.keys {
+		if expires.IsZero() && key != except {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// removeExpiring removes the key if it still expires at expires,
+// i.e. it was not added again, reporting if it was removed.
+func (k *WebhookKeys) removeExpiring(key string, expires time.Time) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if e, ok := k.keys[key]; !ok || !e.Equal(expires) {
+		return false
+	}
+	delete(k.keys, key)
+	return true
+}
+
+// Len returns the number of keys in the set, expired or not.
+func (k *WebhookKeys) Len() int {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return len(k.keys)
+}
+
+// KeyRotationStage is a step of RotateKey.
+type KeyRotationStage int
+
+// Stages of RotateKey.
+const (
+	// The new key is accepted by the receiver.
+	KeyAdded KeyRotationStage = iota
+	// The webhook is updated to the new key in the API.
+	KeyRegistered
+	// The old key is accepted until the end of the grace period.
+	KeyRetiring
+	// The old key is removed.
+	KeyRetired
+	// The rotation failed. The new key is removed, unless the webhook
+	// may have been updated. See KeyRotationEvent.Err.
+	KeyRotationFailed
+)
+
+// KeyRotationEvent is a step of RotateKey. The keys are masked.
+type KeyRotationEvent struct {
+	Stage KeyRotationStage
+	Key   string
+	Err   error
+	At    time.Time
+}
+
+// RotateOptions configures RotateKey. The zero value is valid.
+type RotateOptions struct {
+	// Time the old key is accepted after the rotation.
+	// DefaultKeyRotationGrace is used if <= 0.
+	Grace time.Duration
+	// OnEvent is called on each step of the rotation, if set.
+	OnEvent func(e *KeyRotationEvent)
+}
+
+func (o *RotateOptions) emit(stage KeyRotationStage, key string, err error) {
+	if o.OnEvent != nil {
+		o.OnEvent(&KeyRotationEvent{Stage: stage, Key: maskKey(key), Err: err, At: time.Now()})
+	}
+}
+
+// RotateKey replaces the AuthKey of the registered webhook by newKey without
+// dropping deliveries. The new key is added to keys before updating the webhook,
+// and the update is verified by reading the webhook back. Then the old keys, the
+// ones in keys without expiry, are accepted for the grace period, and removed
+// afterwards.
+//
+// If the rotation fails after the webhook may have been updated, both the new
+// and the old keys are kept, as the API may be sending either.
+//
+// keys should be the Keys of the WebhookHandler receiving the webhook, as the ones
+// of NewWebhookHandler. The AuthKey of the handler, if set, is never retired.
+// opts may be nil.
+func (ws *WebhookService) RotateKey(ctx context.Context, keys *WebhookKeys, newKey string, opts *RotateOptions) error {
+	var o RotateOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Grace <= 0 {
+		o.Grace = DefaultKeyRotationGrace
+	}
+
+	old := keys.permanent(newKey)
+	known := keys.has(newKey)
+
+	keys.Add(newKey, time.Time{})
+	o.emit(KeyAdded, newKey, nil)
+
+	if updated, err := ws.rotate(ctx, newKey); err != nil {
+		if !updated && !known {
+			keys.Remove(newKey)
+		}
+		o.emit(KeyRotationFailed, newKey, err)
+		return err
+	}
+	o.emit(KeyRegistered, newKey, nil)
+
+	if len(old) == 0 {
+		return nil
+	}
+
+	expires := time.Now().Add(o.Grace)
+	for _, key := range old {
+		keys.Add(key, expires)
+		o.emit(KeyRetiring, key, nil)
+	}
+
+	time.AfterFunc(o.Grace, func() {
+		for _, key := range old {
+			if keys.removeExpiring(key, expires) {
+				o.emit(KeyRetired, key, nil)
+			}
+		}
+	})
+
+	return nil
+}
+
+// rotate updates the webhook to the new key. updated reports if the webhook
+// may have been updated, even if an error is returned, i.e. the update timed out.
+func (ws *WebhookService) rotate(ctx context.Context, newKey string) (updated bool, err error) {
+	wr, err := ws.Read(ctx)
+	if err != nil {
+		return false, err
+	}
+	if len(wr.Listeners) == 0 {
+		return false, ErrNoWebhook
+	}
+
+	cur := wr.Listeners[0]
+	desired := *cur
+	desired.AuthKey = newKey
+
+	if _, err := ws.Update(ctx, &desired); err != nil {
+		return ambiguous(err), err
+	}
+
+	if !cur.Active {
+		if _, err := ws.Activate(ctx); err != nil {
+			return true, err
+		}
+	}
+
+	wr, err = ws.Read(ctx)
+	if err != nil {
+		return true, err
+	}
+
+	// The API may omit the key of the listeners.
+	if len(wr.Listeners) == 0 || (wr.Listeners[0].AuthKey != "" && wr.Listeners[0].AuthKey != newKey) {
+		return false, ErrKeyNotPersisted
+	}
+
+	return true, nil
+}
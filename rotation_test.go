@@ -0,0 +1,233 @@
+package wappa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhookKeys(t *testing.T) {
+	now := time.Now()
+	k := NewWebhookKeys("current", "")
+	k.Add("old", now.Add(time.Minute))
+	k.Add("expired", now.Add(-time.Minute))
+
+	testCases := []struct {
+		key  string
+		want bool
+	}{
+		{"current", true},
+		{"old", true},
+		{"expired", false},
+		{"unknown", false},
+		{"", false},
+	}
+
+	for _, tc := range testCases {
+		if got := k.Valid(tc.key, now); got != tc.want {
+			t.Errorf("got Valid(%s): %t; want %t.", tc.key, got, tc.want)
+		}
+	}
+
+	k.Remove("old")
+	if got := k.Len(); got != 2 {
+		t.Errorf("got Len(): %d; want 2.", got)
+	}
+}
+
+func TestWebhookHandlerKeys(t *testing.T) {
+	h := NewWebhookHandler(&Webhook{}, nil)
+	h.Keys = NewWebhookKeys("current", "next")
+
+	for key, want := range map[string]int{
+		"current":     http.StatusOK,
+		"Bearer next": http.StatusOK,
+		"other":       http.StatusUnauthorized,
+		"":            http.StatusUnauthorized,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(testWebhookPayload))
+		req.Header.Set("Authorization", key)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != want {
+			t.Errorf("got status code with key '%s': %d; want %d.", key, rec.Code, want)
+		}
+	}
+}
+
+// staleWebhookAPI is a webhookAPI ignoring the updates.
+type staleWebhookAPI struct {
+	*webhookAPI
+}
+
+func (a staleWebhookAPI) Request(ctx context.Context, method string, path endpoint, body, output interface{}) error {
+	if path == webhookEndpoint.Action(update) {
+		return nil
+	}
+	return a.webhookAPI.Request(ctx, method, path, body, output)
+}
+
+var (
+	errWebhookAPI     = errors.New("Error")
+	errWebhookRefused = &ApiError{StatusCode: http.StatusBadRequest, Err: ErrValidation}
+)
+
+// failingWebhookAPI is a webhookAPI failing the requests to an endpoint with err,
+// or errWebhookAPI if nil, the reads of the webhook only after it is changed.
+type failingWebhookAPI struct {
+	*webhookAPI
+	fail endpoint
+	err  error
+}
+
+func (a failingWebhookAPI) Request(ctx context.Context, method string, path endpoint, body, output interface{}) error {
+	if path == a.fail && (path != webhookEndpoint || len(a.calls) > 0) {
+		if a.err != nil {
+			return a.err
+		}
+		return errWebhookAPI
+	}
+	return a.webhookAPI.Request(ctx, method, path, body, output)
+}
+
+func TestRotateKey(t *testing.T) {
+	api := &webhookAPI{current: &Webhook{URL: "https://example.com", Endpoint: "hooks", AuthKey: "old-key", Active: true}}
+	keys := NewWebhookKeys("old-key")
+
+	var mu sync.Mutex
+	var stages []KeyRotationStage
+	retired := make(chan struct{})
+	opts := &RotateOptions{
+		Grace: 10 * time.Millisecond,
+		OnEvent: func(e *KeyRotationEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			stages = append(stages, e.Stage)
+			if e.Stage == KeyRetired {
+				close(retired)
+			}
+		},
+	}
+
+	if err := (&WebhookService{api}).RotateKey(context.Background(), keys, "new-key", opts); err != nil {
+		t.Fatalf("got error calling RotateKey(): %s; want nil.", err.Error())
+	}
+
+	if api.current.AuthKey != "new-key" || api.current.Endpoint != "hooks" {
+		t.Errorf("got webhook: %+v; want it updated to the new key.", api.current)
+	}
+
+	if !keys.Valid("old-key", time.Now()) || !keys.Valid("new-key", time.Now()) {
+		t.Errorf("got old or new key invalid during the grace period; want both valid.")
+	}
+
+	select {
+	case <-retired:
+	case <-time.After(time.Second):
+		t.Fatalf("got old key not retired; want it retired after the grace period.")
+	}
+
+	if keys.Valid("old-key", time.Now()) || keys.Len() != 1 {
+		t.Errorf("got old key valid after the grace period; want it removed.")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []KeyRotationStage{KeyAdded, KeyRegistered, KeyRetiring, KeyRetired}; !reflect.DeepEqual(stages, want) {
+		t.Errorf("got stages: %v; want %v.", stages, want)
+	}
+}
+
+func TestRotateKeyError(t *testing.T) {
+	testCases := []struct {
+		name    string
+		api     requester
+		wantErr error
+		// If the new key is kept, as the webhook may have been updated.
+		wantNewKey bool
+	}{
+		{"no webhook", &webhookAPI{}, ErrNoWebhook, false},
+		{"not persisted", staleWebhookAPI{&webhookAPI{current: &Webhook{AuthKey: "old-key", Active: true}}}, ErrKeyNotPersisted, false},
+		{"update failed", failingWebhookAPI{&webhookAPI{current: &Webhook{AuthKey: "old-key", Active: true}}, webhookEndpoint.Action(update), nil}, errWebhookAPI, true},
+		{"update refused", failingWebhookAPI{&webhookAPI{current: &Webhook{AuthKey: "old-key", Active: true}}, webhookEndpoint.Action(update), errWebhookRefused}, errWebhookRefused, false},
+		{"activation failed", failingWebhookAPI{&webhookAPI{current: &Webhook{AuthKey: "old-key"}}, webhookEndpoint.Action(activate), nil}, errWebhookAPI, true},
+		{"verification failed", failingWebhookAPI{&webhookAPI{current: &Webhook{AuthKey: "old-key", Active: true}}, webhookEndpoint, nil}, errWebhookAPI, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			keys := NewWebhookKeys("old-key")
+
+			var last *KeyRotationEvent
+			err := (&WebhookService{tc.api}).RotateKey(context.Background(), keys, "new-key", &RotateOptions{
+				Grace:   time.Millisecond,
+				OnEvent: func(e *KeyRotationEvent) { last = e },
+			})
+			if err != tc.wantErr {
+				t.Errorf("got error: %v; want %v.", err, tc.wantErr)
+			}
+
+			// Waits for any retirement of the old key.
+			time.Sleep(10 * time.Millisecond)
+
+			if keys.Valid("new-key", time.Now()) != tc.wantNewKey || !keys.Valid("old-key", time.Now()) {
+				t.Errorf("got new key valid %t and old key valid %t; want %t and true.",
+					keys.Valid("new-key", time.Now()), keys.Valid("old-key", time.Now()), tc.wantNewKey)
+			}
+
+			if last == nil || last.Stage != KeyRotationFailed || last.Err != tc.wantErr {
+				t.Errorf("got last event: %+v; want the failure.", last)
+			}
+		})
+	}
+}
+
+func TestRotateKeyOmittedKey(t *testing.T) {
+	// The API omits the key of the listener, so the old one is taken from keys.
+	api := &webhookAPI{current: &Webhook{URL: "https://example.com", Active: true}}
+	keys := NewWebhookKeys("old-key")
+
+	if err := (&WebhookService{api}).RotateKey(context.Background(), keys, "new-key", &RotateOptions{Grace: time.Millisecond}); err != nil {
+		t.Fatalf("got error calling RotateKey(): %s; want nil.", err.Error())
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	if keys.Valid("old-key", time.Now()) || !keys.Valid("new-key", time.Now()) || keys.Len() != 1 {
+		t.Errorf("got old key valid after the grace period; want only the new key.")
+	}
+}
+
+func TestRotateKeyHandler(t *testing.T) {
+	api := &webhookAPI{current: &Webhook{URL: "https://example.com", AuthKey: "old-key", Active: true}}
+	h := NewWebhookHandler(api.current, nil)
+
+	if err := (&WebhookService{api}).RotateKey(context.Background(), h.Keys, "new-key", &RotateOptions{Grace: time.Millisecond}); err != nil {
+		t.Fatalf("got error calling RotateKey(): %s; want nil.", err.Error())
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	for key, want := range map[string]int{
+		"old-key": http.StatusUnauthorized,
+		"new-key": http.StatusOK,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(testWebhookPayload))
+		req.Header.Set("Authorization", key)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != want {
+			t.Errorf("got status code with key '%s' after the rotation: %d; want %d.", key, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package wappa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// ErrRouterClosed is returned when dispatching rides to a closed WebhookRouter.
+var ErrRouterClosed = errors.New("wappa: webhook router closed")
+
+// RideHandler handles a ride received by the webhook.
+type RideHandler interface {
+	HandleRide(ctx context.Context, r *WebhookRide) error
+}
+
+// RideHandlerFunc is an adapter to allow the use of ordinary functions as RideHandler.
+type RideHandlerFunc func(ctx context.Context, r *WebhookRide) error
+
+// HandleRide calls f(ctx, r).
+func (f RideHandlerFunc) HandleRide(ctx context.Context, r *WebhookRide) error {
+	return f(ctx, r)
+}
+
+// RideMiddleware wraps the handlers of a WebhookRouter.
+type RideMiddleware func(RideHandler) RideHandler
+
+// DispatchMode defines how a WebhookRouter runs the handlers.
+type DispatchMode int
+
+// Dispatch modes.
+const (
+	// The handlers run in the request goroutine, and their
+	// errors are answered to the API so it delivers the ride again.
+	DispatchSync DispatchMode = iota
+	// The handlers run in a bounded pool of workers, in any order.
+	DispatchPool
+	// The handlers run in a bounded pool of workers, and the
+	// deliveries of the same ride are handled in arrival order.
+	DispatchOrdered
+)
+
+// Size of the queue of each worker of a WebhookRouter.
+const routerQueueSize = 64
+
+// WebhookRouter dispatches the rides received by a WebhookHandler
+// to the handlers registered for their status.
+//
+// Handler panics are recovered and, as handler errors, reported to OnError.
+type WebhookRouter struct {
+	// OnError is called with the errors and panics of the handlers, if set.
+	OnError func(r *WebhookRide, err error)
+
+	mode DispatchMode
+
+	// Guards the handlers, apart from the queues so
+	// workers don't wait on blocked dispatches.
+	hmu        sync.RWMutex
+	handlers   map[RideStatus][]RideHandler
+	wildcard   []RideHandler
+	middleware []RideMiddleware
+
+	mu     sync.RWMutex
+	closed bool
+	queues []chan queuedRide
+	wg     sync.WaitGroup
+}
+
+// queuedRide is a ride queued by an asynchronous router, with the
+// values of the context of its delivery kept for the handlers.
+type queuedRide struct {
+	ride       *WebhookRide
+	outOfOrder bool
+}
+
+// NewWebhookRouter returns a WebhookRouter running the handlers according to mode.
+// workers is the size of the pool of the asynchronous modes, at least one.
+func NewWebhookRouter(mode DispatchMode, workers int) *WebhookRouter {
+	r := &WebhookRouter{
+		mode:     mode,
+		handlers: make(map[RideStatus][]RideHandler),
+	}
+
+	if mode == DispatchSync {
+		return r
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	// The pool shares a single queue, while each ordered worker
+	// has its own, receiving always the same rides.
+	queues := 1
+	if mode == DispatchOrdered {
+		queues = workers
+	}
+
+	r.queues = make([]chan queuedRide, queues)
+	for i := range r.queues {
+		r.queues[i] = make(chan queuedRide, routerQueueSize)
+	}
+
+	for i := 0; i < workers; i++ {
+		r.wg.Add(1)
+		go r.work(r.queues[i%queues])
+	}
+
+	return r
+}
+
+// Handle registers a handler for the rides with the given status.
+func (r *WebhookRouter) Handle(status RideStatus, h RideHandler) {
+	r.hmu.Lock()
+	defer r.hmu.Unlock()
+
+	r.handlers[status] = append(r.handlers[status], h)
+}
+
+// HandleAll registers a handler for the rides of any status.
+func (r *WebhookRouter) HandleAll(h RideHandler) {
+	r.hmu.Lock()
+	defer r.hmu.Unlock()
+
+	r.wildcard = append(r.wildcard, h)
+}
+
+// Use appends middlewares wrapping every handler. The first middleware is the outermost.
+func (r *WebhookRouter) Use(mw ...RideMiddleware) {
+	r.hmu.Lock()
+	defer r.hmu.Unlock()
+
+	r.middleware = append(r.middleware, mw...)
+}
+
+// OnSearchingForDriver registers a handler for the rides searching for a driver.
+func (r *WebhookRouter) OnSearchingForDriver(f RideHandlerFunc) {
+	r.Handle(RideStatusSearchingForDriver, f)
+}
+
+// OnDriverNotFound registers a handler for the rides without a driver found.
+func (r *WebhookRouter) OnDriverNotFound(f RideHandlerFunc) {
+	r.Handle(RideStatusDriverNotFound, f)
+}
+
+// OnCancelled registers a handler for the cancelled rides.
+func (r *WebhookRouter) OnCancelled(f RideHandlerFunc) {
+	r.Handle(RideStatusCancelled, f)
+}
+
+// OnDriverFound registers a handler for the rides with a driver found.
+func (r *WebhookRouter) OnDriverFound(f RideHandlerFunc) {
+	r.Handle(RideStatusDriverFound, f)
+}
+
+// OnWaitingForDriver registers a handler for the rides waiting for the driver.
+func (r *WebhookRouter) OnWaitingForDriver(f RideHandlerFunc) {
+	r.Handle(RideStatusWaitingForDriver, f)
+}
+
+// OnInProgress registers a handler for the rides in progress.
+func (r *WebhookRouter) OnInProgress(f RideHandlerFunc) {
+	r.Handle(RideStatusInProgress, f)
+}
+
+// OnPaid registers a handler for the paid rides.
+func (r *WebhookRouter) OnPaid(f RideHandlerFunc) {
+	r.Handle(RideStatusPaid, f)
+}
+
+// OnCompleted registers a handler for the completed rides.
+func (r *WebhookRouter) OnCompleted(f RideHandlerFunc) {
+	r.Handle(RideStatusCompleted, f)
+}
+
+// Handler returns a WebhookHandler for the webhook dispatching the rides to the router.
+func (r *WebhookRouter) Handler(wh *Webhook) *WebhookHandler {
+	return NewWebhookHandler(wh, r.Dispatch)
+}
+
+// Dispatch runs the handlers of the ride. In the synchronous mode it returns
+// the first error of the handlers; otherwise the ride is queued, blocking
+// while the queue is full.
+func (r *WebhookRouter) Dispatch(ctx context.Context, ride *WebhookRide) error {
+	if r.mode == DispatchSync {
+		return r.run(ctx, ride)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return ErrRouterClosed
+	}
+
+	q := r.queues[0]
+	if r.mode == DispatchOrdered {
+		q = r.queues[uint(ride.RideID)%uint(len(r.queues))]
+	}
+
+	select {
+	case q <- queuedRide{ride, OutOfOrder(ctx)}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting rides and waits for the queued ones to be handled.
+func (r *WebhookRouter) Close() {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.closed = true
+	for _, q := range r.queues {
+		close(q)
+	}
+	r.mu.Unlock()
+
+	r.wg.Wait()
+}
+
+func (r *WebhookRouter) work(q <-chan queuedRide) {
+	defer r.wg.Done()
+
+	for qr := range q {
+		// The request of the ride is already answered, so only
+		// the flag of its delivery is kept from its context.
+		ctx := context.Background()
+		if qr.outOfOrder {
+			ctx = context.WithValue(ctx, outOfOrderKey{}, true)
+		}
+		r.run(ctx, qr.ride)
+	}
+}
+
+// run calls the handlers of the ride, returning the first error.
+func (r *WebhookRouter) run(ctx context.Context, ride *WebhookRide) error {
+	r.hmu.RLock()
+	handlers := append(append([]RideHandler(nil), r.handlers[ride.Status]...), r.wildcard...)
+	middleware := r.middleware
+	r.hmu.RUnlock()
+
+	var first error
+	for _, h := range handlers {
+		for i := len(middleware) - 1; i >= 0; i-- {
+			h = middleware[i](h)
+		}
+
+		if err := r.call(ctx, h, ride); err != nil {
+			if r.OnError != nil {
+				r.OnError(ride, err)
+			}
+			if first == nil {
+				first = err
+			}
+		}
+	}
+
+	return first
+}
+
+// call runs the handler, recovering from its panics.
+func (r *WebhookRouter) call(ctx context.Context, h RideHandler, ride *WebhookRide) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("wappa: panic handling ride %d: %v\n%s", ride.RideID, v, debug.Stack())
+		}
+	}()
+
+	return h.HandleRide(ctx, ride)
+}
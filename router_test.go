@@ -0,0 +1,158 @@
+package wappa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWebhookRouterSync(t *testing.T) {
+	r := NewWebhookRouter(DispatchSync, 0)
+
+	var calls []string
+	r.Use(func(next RideHandler) RideHandler {
+		return RideHandlerFunc(func(ctx context.Context, ride *WebhookRide) error {
+			calls = append(calls, "middleware")
+			return next.HandleRide(ctx, ride)
+		})
+	})
+	r.OnDriverFound(func(ctx context.Context, ride *WebhookRide) error {
+		calls = append(calls, "driver-found")
+		return nil
+	})
+	r.OnCompleted(func(ctx context.Context, ride *WebhookRide) error {
+		calls = append(calls, "completed")
+		return nil
+	})
+	r.HandleAll(RideHandlerFunc(func(ctx context.Context, ride *WebhookRide) error {
+		calls = append(calls, "all")
+		return nil
+	}))
+
+	if err := r.Dispatch(context.Background(), &WebhookRide{Status: RideStatusDriverFound}); err != nil {
+		t.Fatalf("got error calling Dispatch(): %s; want nil.", err.Error())
+	}
+
+	if want := []string{"middleware", "driver-found", "middleware", "all"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("got calls: %v; want %v.", calls, want)
+	}
+}
+
+func TestWebhookRouterErrors(t *testing.T) {
+	r := NewWebhookRouter(DispatchSync, 0)
+
+	wantErr := errors.New("failed")
+	var reported []error
+	r.OnError = func(ride *WebhookRide, err error) {
+		reported = append(reported, err)
+	}
+
+	r.OnInProgress(func(ctx context.Context, ride *WebhookRide) error {
+		panic("boom")
+	})
+	r.OnInProgress(func(ctx context.Context, ride *WebhookRide) error {
+		return wantErr
+	})
+
+	err := r.Dispatch(context.Background(), &WebhookRide{RideID: 1, Status: RideStatusInProgress})
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("got error: %v; want the panic.", err)
+	}
+
+	if len(reported) != 2 || reported[1] != wantErr {
+		t.Errorf("got reported errors: %v; want the panic and %v.", reported, wantErr)
+	}
+}
+
+func TestWebhookRouterAsync(t *testing.T) {
+	for _, mode := range []DispatchMode{DispatchPool, DispatchOrdered} {
+		r := NewWebhookRouter(mode, 4)
+
+		var handled int32
+		var mu sync.Mutex
+		codes := map[int][]int{}
+		r.HandleAll(RideHandlerFunc(func(ctx context.Context, ride *WebhookRide) error {
+			atomic.AddInt32(&handled, 1)
+			mu.Lock()
+			codes[ride.RideID] = append(codes[ride.RideID], ride.Code)
+			mu.Unlock()
+			return nil
+		}))
+
+		for code := 0; code < 50; code++ {
+			for id := 1; id <= 5; id++ {
+				if err := r.Dispatch(context.Background(), &WebhookRide{RideID: id, Code: code}); err != nil {
+					t.Fatalf("got error calling Dispatch(): %s; want nil.", err.Error())
+				}
+			}
+		}
+
+		r.Close()
+
+		if got := atomic.LoadInt32(&handled); got != 250 {
+			t.Errorf("got %d rides handled in mode %d; want 250.", got, mode)
+		}
+
+		if mode == DispatchOrdered {
+			for id, cs := range codes {
+				for i, c := range cs {
+					if c != i {
+						t.Fatalf("got ride %d deliveries out of order: %v.", id, cs)
+					}
+				}
+			}
+		}
+
+		if err := r.Dispatch(context.Background(), &WebhookRide{}); err != ErrRouterClosed {
+			t.Errorf("got error dispatching to closed router: %v; want %v.", err, ErrRouterClosed)
+		}
+	}
+}
+
+func TestWebhookRouterOutOfOrder(t *testing.T) {
+	for _, mode := range []DispatchMode{DispatchSync, DispatchPool, DispatchOrdered} {
+		r := NewWebhookRouter(mode, 2)
+
+		var mu sync.Mutex
+		flagged := map[int]bool{}
+		r.HandleAll(RideHandlerFunc(func(ctx context.Context, ride *WebhookRide) error {
+			mu.Lock()
+			defer mu.Unlock()
+			flagged[ride.Code] = OutOfOrder(ctx)
+			return nil
+		}))
+
+		r.Dispatch(context.Background(), &WebhookRide{RideID: 1, Code: 1})
+		r.Dispatch(context.WithValue(context.Background(), outOfOrderKey{}, true), &WebhookRide{RideID: 1, Code: 2})
+		r.Close()
+
+		if want := map[int]bool{1: false, 2: true}; !reflect.DeepEqual(flagged, want) {
+			t.Errorf("got out of order flags in mode %d: %v; want %v.", mode, flagged, want)
+		}
+	}
+}
+
+func TestWebhookRouterHandler(t *testing.T) {
+	r := NewWebhookRouter(DispatchSync, 0)
+	r.OnCancelled(func(ctx context.Context, ride *WebhookRide) error {
+		return errors.New("failed")
+	})
+
+	h := r.Handler(&Webhook{AuthKey: "auth-key"})
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"status": "ride-cancelled"}`))
+	req.Header.Set("Authorization", "auth-key")
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("got status code: %d; want %d.", rec.Code, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,272 @@
+package wappa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// Scenario is a ride lifecycle replayed by the Simulator.
+type Scenario int
+
+// Scenarios of the Simulator.
+const (
+	// The ride is completed.
+	ScenarioCompleted Scenario = iota
+	// The ride is cancelled while the driver is on the way.
+	ScenarioCancelled
+	// No driver accepts the ride.
+	ScenarioDriverNotFound
+)
+
+var scenarioStatuses = map[Scenario][]RideStatus{
+	ScenarioCompleted: {
+		RideStatusSearchingForDriver,
+		RideStatusDriverFound,
+		RideStatusWaitingForDriver,
+		RideStatusInProgress,
+		RideStatusCompleted,
+	},
+	ScenarioCancelled: {
+		RideStatusSearchingForDriver,
+		RideStatusDriverFound,
+		RideStatusWaitingForDriver,
+		RideStatusCancelled,
+	},
+	ScenarioDriverNotFound: {
+		RideStatusSearchingForDriver,
+		RideStatusDriverNotFound,
+	},
+}
+
+var scenarioNames = map[string]Scenario{
+	"completed":        ScenarioCompleted,
+	"cancelled":        ScenarioCancelled,
+	"driver-not-found": ScenarioDriverNotFound,
+}
+
+// ParseScenario returns the scenario named completed, cancelled or driver-not-found.
+func ParseScenario(name string) (Scenario, error) {
+	if sc, ok := scenarioNames[name]; ok {
+		return sc, nil
+	}
+	return 0, fmt.Errorf("wappa: unknown scenario %q", name)
+}
+
+// Statuses returns the statuses the ride goes through in the scenario.
+func (s Scenario) Statuses() []RideStatus {
+	return append([]RideStatus(nil), scenarioStatuses[s]...)
+}
+
+// Default delay between the deliveries of the Simulator.
+const DefaultSimulatorInterval = time.Second
+
+// Simulator delivers the webhook payloads of ride lifecycles to a receiver,
+// so it can be tested without live rides. The deliveries may be duplicated
+// and reordered, as the API does.
+type Simulator struct {
+	// URL of the receiver.
+	URL string
+	// AuthKey sent in the AuthHeader.
+	AuthKey string
+	// DefaultWebhookAuthHeader is used if empty.
+	AuthHeader string
+	// http.DefaultClient is used if nil.
+	Client *http.Client
+	// Delay between deliveries. DefaultSimulatorInterval is used if zero,
+	// and none if negative.
+	Interval time.Duration
+	// Maximum random delay added to Interval.
+	Jitter time.Duration
+	// Probability, from 0 to 1, of delivering a payload twice.
+	DuplicateRate float64
+	// Probability, from 0 to 1, of delivering a payload after the next one.
+	ReorderRate float64
+	// Source of randomness. A source seeded by the current time is used if nil.
+	Rand *rand.Rand
+	// Origin and destiny of the rides.
+	Origin  Location
+	Destiny Location
+	// OnDeliver is called with the response status of each delivery, or its error, if set.
+	OnDeliver func(r *WebhookRide, status int, err error)
+}
+
+// NewSimulator returns a Simulator delivering to the URL with the AuthKey.
+func NewSimulator(url, authKey string) *Simulator {
+	return &Simulator{
+		URL:     url,
+		AuthKey: authKey,
+		Origin:  Location{-23.5505, -46.6333},
+		Destiny: Location{-23.5874, -46.6576},
+	}
+}
+
+func (s *Simulator) rand() *rand.Rand {
+	if s.Rand == nil {
+		s.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return s.Rand
+}
+
+// Rides returns the payloads of the ride in the scenario, in order.
+func (s *Simulator) Rides(rideID int, sc Scenario) []*WebhookRide {
+	statuses := sc.Statuses()
+	rides := make([]*WebhookRide, len(statuses))
+
+	// Minutes of the driver to the origin, and of the trip.
+	toOrigin, toDestiny := 8, 20
+	for i, st := range statuses {
+		r := &WebhookRide{
+			Code:            i + 1,
+			RideID:          rideID,
+			CompanyID:       1,
+			EmployeeID:      1,
+			Status:          st,
+			OriginLocation:  s.Origin,
+			DestinyLocation: s.Destiny,
+			ExternalID:      fmt.Sprintf("sim-%d", rideID),
+		}
+
+		switch st {
+		case RideStatusDriverFound, RideStatusWaitingForDriver:
+			// The driver approaches the origin.
+			left := toOrigin
+			if st == RideStatusWaitingForDriver {
+				left = toOrigin / 4
+			}
+			r.TaxiLocation = towards(s.Origin, s.Destiny, -float64(left)/100)
+			r.TimeToOriginSec = left * 60
+			r.TimeToOrigin = Duration{time.Duration(left) * time.Minute}
+			r.DistanceToOriginKM = left / 2
+		case RideStatusInProgress:
+			r.TaxiLocation = towards(s.Origin, s.Destiny, 0.5)
+			r.TimeToDestinySec = toDestiny * 30
+			r.TimeToDestiny = Duration{time.Duration(toDestiny) * 30 * time.Second}
+		case RideStatusCompleted:
+			r.TaxiLocation = s.Destiny
+			r.RideValue = 32.5
+		}
+
+		rides[i] = r
+	}
+
+	return rides
+}
+
+// towards returns the location at the fraction of the way from a to b.
+func towards(a, b Location, f float64) Location {
+	return Location{a.Lat + (b.Lat-a.Lat)*f, a.Lng + (b.Lng-a.Lng)*f}
+}
+
+// Plan returns the deliveries of the rides, duplicated and
+// reordered according to DuplicateRate and ReorderRate.
+func (s *Simulator) Plan(rides []*WebhookRide) []*WebhookRide {
+	rnd := s.rand()
+
+	plan := make([]*WebhookRide, 0, len(rides))
+	for _, r := range rides {
+		plan = append(plan, r)
+		if rnd.Float64() < s.DuplicateRate {
+			plan = append(plan, r)
+		}
+	}
+
+	for i := 0; i < len(plan)-1; i++ {
+		if rnd.Float64() < s.ReorderRate {
+			plan[i], plan[i+1] = plan[i+1], plan[i]
+			i++
+		}
+	}
+
+	return plan
+}
+
+// Run delivers the ride in the scenario, waiting Interval and Jitter between the
+// deliveries. It stops at the first failed delivery, or when ctx is done.
+func (s *Simulator) Run(ctx context.Context, rideID int, sc Scenario) error {
+	return s.Replay(ctx, s.Plan(s.Rides(rideID, sc)))
+}
+
+// Replay delivers the payloads in order, waiting Interval and Jitter between them.
+func (s *Simulator) Replay(ctx context.Context, rides []*WebhookRide) error {
+	for i, r := range rides {
+		if i > 0 {
+			if err := sleep(ctx, s.delay()); err != nil {
+				return err
+			}
+		}
+
+		if err := s.Deliver(ctx, r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Simulator) delay() time.Duration {
+	d := s.Interval
+	if d == 0 {
+		d = DefaultSimulatorInterval
+	}
+	if d < 0 {
+		d = 0
+	}
+	if s.Jitter > 0 {
+		d += time.Duration(s.rand().Int63n(int64(s.Jitter)))
+	}
+	return d
+}
+
+// Deliver posts the payload to the receiver. Responses other than 200 OK are errors.
+func (s *Simulator) Deliver(ctx context.Context, r *WebhookRide) error {
+	status, err := s.deliver(ctx, r)
+	if s.OnDeliver != nil {
+		s.OnDeliver(r, status, err)
+	}
+	return err
+}
+
+func (s *Simulator) deliver(ctx context.Context, r *WebhookRide) (int, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(b))
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+
+	header := s.AuthHeader
+	if header == "" {
+		header = DefaultWebhookAuthHeader
+	}
+	req.Header.Set(header, s.AuthKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		return res.StatusCode, fmt.Errorf("wappa: webhook delivery of ride %d (%s) answered %s", r.RideID, r.Status, res.Status)
+	}
+
+	return res.StatusCode, nil
+}
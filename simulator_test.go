@@ -0,0 +1,93 @@
+package wappa
+
+import (
+	"context"
+	"math/rand"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSimulatorRun(t *testing.T) {
+	testCases := []struct {
+		scenario Scenario
+		want     []RideStatus
+	}{
+		{ScenarioCompleted, ScenarioCompleted.Statuses()},
+		{ScenarioCancelled, []RideStatus{RideStatusSearchingForDriver, RideStatusDriverFound, RideStatusWaitingForDriver, RideStatusCancelled}},
+		{ScenarioDriverNotFound, []RideStatus{RideStatusSearchingForDriver, RideStatusDriverNotFound}},
+	}
+
+	for _, tc := range testCases {
+		var mu sync.Mutex
+		var got []RideStatus
+		h := NewWebhookHandler(&Webhook{AuthKey: "auth-key"}, func(ctx context.Context, r *WebhookRide) error {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, r.Status)
+			return nil
+		})
+		h.Filter = NewDeliveryFilter(100, time.Minute)
+		s := httptest.NewServer(h)
+
+		var deliveries int
+		sim := NewSimulator(s.URL, "auth-key")
+		sim.Interval = -1
+		sim.DuplicateRate = 1
+		sim.OnDeliver = func(r *WebhookRide, status int, err error) {
+			deliveries++
+		}
+
+		if err := sim.Run(context.Background(), 7, tc.scenario); err != nil {
+			t.Fatalf("got error calling Run(): %s; want nil.", err.Error())
+		}
+		s.Close()
+
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("got statuses: %v; want %v.", got, tc.want)
+		}
+
+		if want := 2 * len(tc.want); deliveries != want {
+			t.Errorf("got %d deliveries; want %d.", deliveries, want)
+		}
+	}
+}
+
+func TestSimulatorUnauthorized(t *testing.T) {
+	s := httptest.NewServer(NewWebhookHandler(&Webhook{AuthKey: "auth-key"}, nil))
+	defer s.Close()
+
+	sim := NewSimulator(s.URL, "wrong-key")
+	if err := sim.Run(context.Background(), 1, ScenarioCompleted); err == nil {
+		t.Errorf("got nil error delivering with the wrong key; want error.")
+	}
+}
+
+func TestSimulatorPlan(t *testing.T) {
+	sim := NewSimulator("", "")
+	sim.Rand = rand.New(rand.NewSource(1))
+	sim.ReorderRate = 1
+
+	rides := sim.Rides(1, ScenarioCancelled)
+
+	var got []int
+	for _, r := range sim.Plan(rides) {
+		got = append(got, r.Code)
+	}
+
+	if want := []int{2, 1, 4, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got codes delivered: %v; want %v.", got, want)
+	}
+}
+
+func TestParseScenario(t *testing.T) {
+	if got, err := ParseScenario("driver-not-found"); err != nil || got != ScenarioDriverNotFound {
+		t.Errorf("got ParseScenario(driver-not-found): %d, %v; want %d, nil.", got, err, ScenarioDriverNotFound)
+	}
+
+	if _, err := ParseScenario("crashed"); err == nil {
+		t.Errorf("got nil error parsing unknown scenario; want error.")
+	}
+}
@@ -0,0 +1,174 @@
+package wappa
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+// Tables of SQLRideEventStore.
+const (
+	rideEventsTable     = "wappa_ride_events"
+	rideMigrationsTable = "wappa_ride_event_migrations"
+)
+
+// Schema migrations of SQLRideEventStore, applied in order.
+// Applied migrations must never change; append new ones instead.
+var rideEventMigrations = [][]string{
+	{
+		`CREATE TABLE ` + rideEventsTable + ` (
+			id INTEGER PRIMARY KEY,
+			ride_id INTEGER NOT NULL,
+			employee_id INTEGER NOT NULL DEFAULT 0,
+			external_id TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL,
+			source TEXT NOT NULL,
+			payload BLOB,
+			occurred_at INTEGER NOT NULL
+		)`,
+		`CREATE INDEX ` + rideEventsTable + `_ride ON ` + rideEventsTable + ` (ride_id, occurred_at)`,
+		`CREATE INDEX ` + rideEventsTable + `_employee ON ` + rideEventsTable + ` (employee_id, occurred_at)`,
+		`CREATE INDEX ` + rideEventsTable + `_external ON ` + rideEventsTable + ` (external_id, occurred_at)`,
+	},
+}
+
+const insertRideEvent = `INSERT INTO ` + rideEventsTable + `
+	(ride_id, employee_id, external_id, status, source, payload, occurred_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+// Every filter is disabled by its zero value, so
+// a single statement serves all the queries.
+const selectRideEvents = `SELECT id, ride_id, employee_id, external_id, status, source, payload, occurred_at
+	FROM ` + rideEventsTable + `
+	WHERE (? = 0 OR ride_id = ?)
+	AND (? = 0 OR employee_id = ?)
+	AND (? = '' OR external_id = ?)
+	AND (? = 0 OR occurred_at >= ?)
+	AND (? = 0 OR occurred_at < ?)
+	ORDER BY occurred_at, id`
+
+// SQLRideEventStore is a RideEventStore on a database/sql database. The statements
+// are SQLite compatible and use ? placeholders. The times are kept as Unix
+// nanoseconds, so they are portable across databases.
+//
+// Migrate must be called before using the store.
+type SQLRideEventStore struct {
+	db *sql.DB
+}
+
+// NewSQLRideEventStore returns a SQLRideEventStore on the database.
+func NewSQLRideEventStore(db *sql.DB) *SQLRideEventStore {
+	return &SQLRideEventStore{db: db}
+}
+
+// Migrate creates or updates the schema of the store, applying the migrations
+// not applied yet, each in a transaction.
+func (s *SQLRideEventStore) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+rideMigrationsTable+` (version INTEGER PRIMARY KEY)`); err != nil {
+		return err
+	}
+
+	var version int
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM `+rideMigrationsTable).Scan(&version); err != nil {
+		return err
+	}
+
+	for v := version + 1; v <= len(rideEventMigrations); v++ {
+		if err := s.migrate(ctx, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SQLRideEventStore) migrate(ctx context.Context, version int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range rideEventMigrations[version-1] {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO `+rideMigrationsTable+` (version) VALUES (?)`, version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Append implements the RideEventStore interface.
+func (s *SQLRideEventStore) Append(ctx context.Context, r *RideEventRecord) error {
+	res, err := s.db.ExecContext(ctx, insertRideEvent,
+		r.RideID, r.EmployeeID, r.ExternalID, string(r.Status), r.Source.String(), r.Payload, r.At.UnixNano())
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	r.ID = id
+
+	return nil
+}
+
+// Query implements the RideEventStore interface.
+func (s *SQLRideEventStore) Query(ctx context.Context, q RideEventQuery) ([]*RideEventRecord, error) {
+	query := selectRideEvents
+	if q.Limit > 0 {
+		query += " LIMIT " + strconv.Itoa(q.Limit)
+	}
+
+	from, to := unixNano(q.From), unixNano(q.To)
+	rows, err := s.db.QueryContext(ctx, query,
+		q.RideID, q.RideID,
+		q.EmployeeID, q.EmployeeID,
+		q.ExternalID, q.ExternalID,
+		from, from,
+		to, to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []*RideEventRecord
+	for rows.Next() {
+		var (
+			r      RideEventRecord
+			status string
+			source string
+			at     int64
+		)
+		if err := rows.Scan(&r.ID, &r.RideID, &r.EmployeeID, &r.ExternalID, &status, &source, &r.Payload, &at); err != nil {
+			return nil, err
+		}
+
+		// Unknown statuses are kept as seen, not to lose history.
+		r.Status = RideStatus(status)
+		if r.Source, err = parseEventSource(source); err != nil {
+			return nil, err
+		}
+		r.At = time.Unix(0, at)
+
+		res = append(res, &r)
+	}
+
+	return res, rows.Err()
+}
+
+// unixNano returns the Unix time of t in nanoseconds, or 0 if t is zero.
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
@@ -0,0 +1,68 @@
+//go:build sqlite
+// +build sqlite
+
+// The tests of SQLRideEventStore run on SQLite, which requires cgo:
+//
+//	go test -tags sqlite
+package wappa
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openSQLite returns an in-memory SQLite database, on a single
+// connection as each one would open a database of its own.
+func openSQLite(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("got error opening SQLite: %s; want nil.", err.Error())
+	}
+	db.SetMaxOpenConns(1)
+	return db
+}
+
+func TestSQLRideEventStoreMigrate(t *testing.T) {
+	db := openSQLite(t)
+	defer db.Close()
+	s := NewSQLRideEventStore(db)
+
+	for i := 0; i < 2; i++ {
+		if err := s.Migrate(context.Background()); err != nil {
+			t.Fatalf("got error calling Migrate() #%d: %s; want nil.", i+1, err.Error())
+		}
+	}
+
+	rows, err := db.Query(`SELECT version FROM ` + rideMigrationsTable + ` ORDER BY version`)
+	if err != nil {
+		t.Fatalf("got error querying the migrations: %s; want nil.", err.Error())
+	}
+	defer rows.Close()
+
+	var versions []int
+	for rows.Next() {
+		var v int
+		rows.Scan(&v)
+		versions = append(versions, v)
+	}
+
+	if want := []int{1}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("got migrations applied: %v; want %v.", versions, want)
+	}
+}
+
+func TestSQLRideEventStore(t *testing.T) {
+	db := openSQLite(t)
+	defer db.Close()
+
+	s := NewSQLRideEventStore(db)
+	if err := s.Migrate(context.Background()); err != nil {
+		t.Fatalf("got error calling Migrate(): %s; want nil.", err.Error())
+	}
+
+	testRideEventStore(t, s)
+}
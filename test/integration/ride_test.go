@@ -49,7 +49,7 @@ func TestRide(t *testing.T) {
 
 	fmt.Println("Waiting for Webhook response.")
 
-	ok, got, want := checkWebhookStatuses(wh, []string{
+	ok, got, want := checkWebhookStatuses(wh, []wappa.RideStatus{
 		wappa.RideStatusSearchingForDriver,
 		wappa.RideStatusDriverFound,
 		wappa.RideStatusInProgress,
@@ -123,7 +123,7 @@ func TestRide(t *testing.T) {
 //
 //	fmt.Println("Waiting for Webhook response.")
 //
-//	ok, got, want := checkWebhookStatuses(wh, []string{
+//	ok, got, want := checkWebhookStatuses(wh, []wappa.RideStatus{
 //		wappa.RideStatusCancelled,
 //	})
 //	svr.Shutdown(context.Background())
@@ -182,7 +182,7 @@ func TestRide(t *testing.T) {
 //
 //	fmt.Println("Waiting for driver to cancel.")
 //
-//	ok, got, want := checkWebhookStatuses(wh, []string{
+//	ok, got, want := checkWebhookStatuses(wh, []wappa.RideStatus{
 //		wappa.RideStatusCancelled,
 //	})
 //	svr.Shutdown(context.Background())
@@ -232,15 +232,6 @@ func webhook(t *testing.T) (<-chan []byte, *http.Server) {
 		t.Skipf(msgEnvMissing, envKeyWappaWebhookPort)
 	}
 
-	r, err := wpp.Webhook.Read(context.Background())
-	if err != nil {
-		t.Fatalf("got error calling Webhook.Read(): '%s'; want nil.", err.Error())
-	}
-
-	if !r.Success {
-		t.Fatalf("got failed response while reading Webhooks: '%s'; want it to be successful.", r.Message)
-	}
-
 	// New webhook endpoint
 	endpoint := fmt.Sprintf("%s", randString(5, letterBytes))
 	wh := &wappa.Webhook{
@@ -249,19 +240,13 @@ func webhook(t *testing.T) (<-chan []byte, *http.Server) {
 		AuthKey:  "auth-key",
 	}
 
-	var curWebhook *wappa.Webhook
-	// If Webhook already exists, updates it. Otherwise creates it.
-	if len(r.Listeners) > 0 {
-		curWebhook = r.Listeners[0]
-
-		updateWebhook(t, wh)
-
-		if !wh.Active {
-			activateWebhook(t)
-		}
-	} else {
-		createWebhook(t, wh)
+	// Creates or updates the Webhook, activating it.
+	diff, err := wpp.Webhook.Ensure(context.Background(), wh, nil)
+	if err != nil {
+		t.Fatalf("got error calling Webhook.Ensure(%+v): '%s'; want nil.", wh, err.Error())
 	}
+	curWebhook := diff.Current
+	waitWebhook(t, wh)
 
 	ch := make(chan []byte)
 
@@ -320,7 +305,11 @@ func updateWebhook(t *testing.T, wh *wappa.Webhook) {
 		t.Fatalf("got failed response while updating Webhooks (%+v): '%s'; want it to be successful.", wh, res.Message)
 	}
 
-	// Wait for the webhook url to be synched before returning.
+	waitWebhook(t, wh)
+}
+
+// waitWebhook waits for the webhook url to be synched before returning.
+func waitWebhook(t *testing.T, wh *wappa.Webhook) {
 	for {
 		r, err := wpp.Webhook.Read(context.Background())
 		if err != nil {
@@ -338,17 +327,6 @@ func updateWebhook(t *testing.T, wh *wappa.Webhook) {
 	}
 }
 
-func createWebhook(t *testing.T, wh *wappa.Webhook) {
-	res, err := wpp.Webhook.Create(context.Background(), wh)
-	if err != nil {
-		t.Fatalf("got error calling Webhook.Create(%+v): '%s'; want nil.", wh, err.Error())
-	}
-
-	if !res.Success {
-		t.Fatalf("got failed response while creating Webhooks (%+v): '%s'; want it to be successful.", wh, res.Message)
-	}
-}
-
 func cancelRide(t *testing.T, rideID int) {
 	r, err := wpp.Ride.CancellationReason(context.Background())
 	if err != nil {
@@ -371,7 +349,7 @@ func cancelRide(t *testing.T, rideID int) {
 	}
 }
 
-func checkWebhookStatuses(c <-chan []byte, statuses []string) (ok bool, got, want string) {
+func checkWebhookStatuses(c <-chan []byte, statuses []wappa.RideStatus) (ok bool, got, want wappa.RideStatus) {
 	var i int
 	for {
 		want = statuses[i]
@@ -382,7 +360,7 @@ func checkWebhookStatuses(c <-chan []byte, statuses []string) (ok bool, got, wan
 		case body := <-c:
 			wr := &wappa.WebhookRide{}
 			if err := json.Unmarshal(body, wr); err != nil {
-				got = err.Error()
+				got = wappa.RideStatus(err.Error())
 				return
 			}
 			fmt.Printf("Status arrived by webhook: '%s'; want '%s'.\n", wr.Status, want)
@@ -111,22 +111,26 @@ func TestMain(m *testing.M) {
 		panic(err.Error())
 	}
 
+	var opts []wappa.ClientOption
+	if *logging {
+		opts = append(opts, wappa.WithMiddleware(func(rt http.RoundTripper) http.RoundTripper {
+			return &transportLogger{rt}
+		}))
+	}
+
 	token := os.Getenv(envKeyWappaToken)
 	if token == "" {
 		fmt.Println("No auth token. Some tests may not run!")
-		wpp = wappa.NewClient(host, nil)
 	} else {
-		host, _ := url.Parse(os.Getenv(envKeyWappaHost))
-
-		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, loggingHTTPClient())
-		hc := oauth2.NewClient(ctx, oauth2.StaticTokenSource(
+		opts = append(opts, wappa.WithTokenSource(oauth2.StaticTokenSource(
 			&oauth2.Token{AccessToken: token},
-		))
-		wpp = wappa.NewClient(host, hc)
+		)))
 
 		auth = true
 	}
 
+	wpp = wappa.NewClient(host, nil, opts...)
+
 	if email := os.Getenv(envKeyWappaEmployeeEmail); email != "" {
 		employeeFilter = wappa.Filter{"email": []string{email}}
 	}
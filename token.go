@@ -3,15 +3,24 @@ package wappa
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"golang.org/x/oauth2"
 )
 
 // TokenSource implements the oauth2.TokenSource interface,
 // in order to reuse the Wappa token.
 type TokenSource struct {
+	// VerifyKey is the public key of the API used to verify the signature of the
+	// tokens: an *rsa.PublicKey, *ecdsa.PublicKey or []byte HMAC secret.
+	// Signatures are not verified if nil.
+	VerifyKey interface{}
+	// ClockSkew tolerated when validating the not-before and issued-at claims.
+	ClockSkew time.Duration
+
 	ctx      context.Context
 	conf     *oauth2.Config
 	username string
@@ -23,12 +32,19 @@ type TokenSource struct {
 // The provided context optionally controls which HTTP client is used. See the oauth2.HTTPClient variable.
 // host should be sufixed by '/'.
 func NewTokenSource(ctx context.Context, host, username, password string) *TokenSource {
+	if !strings.HasSuffix(host, "/") {
+		host += "/"
+	}
+	return newTokenSource(ctx, fmt.Sprintf("%stoken", host), username, password)
+}
+
+func newTokenSource(ctx context.Context, tokenURL, username, password string) *TokenSource {
 	conf := &oauth2.Config{
 		// ClientID and ClientSecret not used in this version
 		ClientID:     "",
 		ClientSecret: "",
 		Endpoint: oauth2.Endpoint{
-			TokenURL: fmt.Sprintf("%stoken", host),
+			TokenURL: tokenURL,
 		},
 	}
 
@@ -47,22 +63,71 @@ func (ts *TokenSource) Token() (*oauth2.Token, error) {
 		return nil, err
 	}
 
-	token, err := jwt.Parse(tk.AccessToken, nil)
-	if token == nil {
-		return nil, fmt.Errorf("invalid JWT token: '%s'.", tk.AccessToken)
+	claims, err := ts.verify(tk.AccessToken)
+	if err != nil {
+		return nil, err
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, fmt.Errorf("token '%s' with invalid claims: '%s'.", tk.AccessToken, err)
+	tk.Expiry = claims.ExpiresAt
+
+	return tk, nil
+}
+
+// tokenVerifier is implemented by the sources verifying their tokens,
+// so the tokens not issued by them, i.e. cached, are verified alike.
+type tokenVerifier interface {
+	verify(accessToken string) (*Claims, error)
+}
+
+// verify returns the claims of the token, checking its signature
+// with VerifyKey, if set, and validating them.
+func (ts *TokenSource) verify(accessToken string) (*Claims, error) {
+	claims, err := parseClaims(accessToken, ts.VerifyKey)
+	if err != nil {
+		return nil, err
 	}
 
-	var expTime time.Time
-	if exp, ok := claims["exp"].(float64); ok {
-		expTime = time.Unix(int64(exp), 0)
+	if err := claims.Validate(time.Now(), ts.ClockSkew); err != nil {
+		return nil, err
 	}
 
-	tk.Expiry = expTime
+	return claims, nil
+}
 
-	return tk, nil
+// NewClientWithCredentials returns a Wappa API client authenticated with the given
+// username and password. Tokens are requested from the token endpoint of host,
+// cached and refreshed ahead of their expiration by a RefreshingTokenSource.
+//
+// The provided context optionally controls which HTTP client is used. See the oauth2.HTTPClient variable.
+func NewClientWithCredentials(ctx context.Context, host *url.URL, username, password string, opts ...ClientOption) *Client {
+	h := *host
+	if !strings.HasSuffix(h.Path, "/") {
+		h.Path += "/"
+	}
+
+	tokenURL := h.ResolveReference(&url.URL{Path: "token"})
+	src := newTokenSource(ctx, tokenURL.String(), username, password)
+	ts := NewRefreshingTokenSource(src, nil)
+
+	hc, _ := ctx.Value(oauth2.HTTPClient).(*http.Client)
+
+	c := NewClient(&h, hc, append([]ClientOption{WithTokenSource(ts)}, opts...)...)
+	c.credentials = ts
+
+	// Set before the first token is requested.
+	src.VerifyKey = c.tokenVerifyKey
+	ts.opts.Cache = c.tokenCache
+	ts.opts.CacheKey = TokenCacheKey(h.String(), username)
+
+	return c
+}
+
+// TokenClaims returns the claims of the current token of a client
+// created by NewClientWithCredentials, fetching it if needed.
+func (c *Client) TokenClaims() (*Claims, error) {
+	if c.credentials == nil {
+		return nil, fmt.Errorf("client has no credentials.")
+	}
+
+	return c.credentials.Claims()
 }
@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -90,3 +94,86 @@ func TestTokenSourceToken(t *testing.T) {
 		tc.server.Close()
 	}
 }
+
+func TestNewClientWithCredentials(t *testing.T) {
+	var tokens int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/token":
+			atomic.AddInt32(&tokens, 1)
+			if err := r.ParseForm(); err != nil || r.Form.Get("username") != "user" || r.Form.Get("password") != "pass" {
+				t.Errorf("got token request form: %v; want username and password.", r.Form)
+			}
+			claims := &jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Hour).Unix(), Subject: "user"}
+			ss, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("AllYourBase"))
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(fmt.Sprintf(`{"access_token": "%s", "token_type": "Bearer"}`, ss)))
+		case "/v1/api/foo":
+			if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+				t.Errorf("got Authorization header: '%s'; want a bearer token.", r.Header.Get("Authorization"))
+			}
+			w.Write([]byte(`{}`))
+		default:
+			t.Errorf("got request to unexpected path: %s.", r.URL.Path)
+		}
+	}))
+	defer s.Close()
+
+	u, _ := url.Parse(s.URL + "/v1")
+	c := NewClientWithCredentials(context.Background(), u, "user", "pass")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Request(context.Background(), http.MethodGet, "foo", nil, &Result{}); err != nil {
+				t.Errorf("got error calling Client.Request(): %s; want nil.", err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+
+	claims, err := c.TokenClaims()
+	if err != nil {
+		t.Fatalf("got error calling Client.TokenClaims(): %s; want nil.", err.Error())
+	}
+
+	if sub := claims.Subject; sub != "user" {
+		t.Errorf("got Claims.Subject: %s; want 'user'.", sub)
+	}
+
+	if got := atomic.LoadInt32(&tokens); got != 1 {
+		t.Errorf("got %d token requests; want 1.", got)
+	}
+}
+
+func TestClientTokenClaimsError(t *testing.T) {
+	c := NewClient(&url.URL{}, nil)
+
+	if _, err := c.TokenClaims(); err == nil {
+		t.Errorf("got error nil; want not nil.")
+	}
+}
+
+func TestTokenSourceTokenVerify(t *testing.T) {
+	testCases := []struct {
+		key     interface{}
+		wantErr bool
+	}{
+		{[]byte("AllYourBase"), false},
+		{[]byte("wrong"), true},
+	}
+
+	for _, tc := range testCases {
+		s := tokenServer(30 * time.Minute)
+
+		ts := NewTokenSource(context.Background(), s.URL+"/", "", "")
+		ts.VerifyKey = tc.key
+
+		if _, err := ts.Token(); (err != nil) != tc.wantErr {
+			t.Errorf("got error calling Token(): %v; want error %t.", err, tc.wantErr)
+		}
+		s.Close()
+	}
+}
@@ -0,0 +1,371 @@
+package wappa
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Defaults of TrackerOptions.
+const (
+	// Time RideTracker waits for a webhook before polling a ride.
+	DefaultFallbackAfter = 30 * time.Second
+	// Time RideTracker keeps the rides after they reach a terminal status.
+	DefaultTrackerRetention = 10 * time.Minute
+)
+
+// TrackerOptions configures a RideTracker. The zero value is valid.
+type TrackerOptions struct {
+	// Time without webhooks after which a non-terminal ride is polled.
+	// DefaultFallbackAfter is used if <= 0.
+	FallbackAfter time.Duration
+	// Interval between polls while no webhook arrives.
+	// DefaultWatchInterval is used if <= 0.
+	PollInterval time.Duration
+	// AutoTrack starts tracking the rides delivered by the webhook
+	// without being tracked. Otherwise their deliveries are ignored.
+	AutoTrack bool
+	// Time the rides are kept after reaching a terminal status, so their
+	// Snapshot is still available, before they are untracked.
+	// DefaultTrackerRetention is used if zero, and they are kept until
+	// Untrack if negative. With AutoTrack, deliveries of a ride after it
+	// is untracked start tracking it again.
+	Retention time.Duration
+	// OnEvent is called with the changes of status of the rides. The calls
+	// of the same ride are made one at a time, in the order of the changes.
+	OnEvent func(e *RideEvent)
+	// OnError is called with the errors polling and recording the rides, if set.
+	OnError func(rideID int, err error)
+	// Events records the changes of status of the rides, including the
+	// ones made through Create and Cancel, if set.
+	Events RideEventStore
+	// Scheduler polling the rides. DefaultWatchScheduler is used if nil.
+	Scheduler *WatchScheduler
+}
+
+// RideTracker tracks the rides from both the webhook and, when its deliveries
+// stop arriving, the polling of the API. The updates of both sources are
+// reconciled by the lifecycle of the ride, so stale ones are ignored.
+// It is safe for concurrent use.
+//
+// The webhook deliveries are received through HandleRide, which makes
+// the tracker usable as a handler of WebhookRouter or WebhookHandler.
+type RideTracker struct {
+	rs   *RideService
+	opts TrackerOptions
+
+	mu     sync.Mutex
+	rides  map[int]*trackedRide
+	closed bool
+}
+
+type trackedRide struct {
+	id int
+
+	mu          sync.Mutex
+	snapshot    RideResult
+	lastWebhook time.Time
+	done        bool
+
+	// Serializes the events of the ride.
+	emu sync.Mutex
+}
+
+// NewRideTracker returns a RideTracker polling the rides with rs. opts may be nil.
+func NewRideTracker(rs *RideService, opts *TrackerOptions) *RideTracker {
+	t := &RideTracker{rs: rs, rides: make(map[int]*trackedRide)}
+	if opts != nil {
+		t.opts = *opts
+	}
+	if t.opts.FallbackAfter <= 0 {
+		t.opts.FallbackAfter = DefaultFallbackAfter
+	}
+	if t.opts.PollInterval <= 0 {
+		t.opts.PollInterval = DefaultWatchInterval
+	}
+	if t.opts.Retention == 0 {
+		t.opts.Retention = DefaultTrackerRetention
+	}
+	if t.opts.Scheduler == nil {
+		t.opts.Scheduler = DefaultWatchScheduler
+	}
+	return t
+}
+
+// Track starts tracking the ride. It is a no-op if it is already tracked.
+func (t *RideTracker) Track(rideID int) {
+	t.track(rideID)
+}
+
+func (t *RideTracker) track(rideID int) *trackedRide {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil
+	}
+
+	if tr, ok := t.rides[rideID]; ok {
+		return tr
+	}
+
+	now := time.Now()
+	tr := &trackedRide{id: rideID, lastWebhook: now}
+	tr.snapshot.ID = rideID
+	t.rides[rideID] = tr
+
+	t.opts.Scheduler.schedule(now.Add(t.opts.FallbackAfter), func() { t.check(tr) })
+
+	return tr
+}
+
+// Untrack stops tracking the ride, forgetting its snapshot.
+func (t *RideTracker) Untrack(rideID int) {
+	t.mu.Lock()
+	tr, ok := t.rides[rideID]
+	delete(t.rides, rideID)
+	t.mu.Unlock()
+
+	if ok {
+		tr.mu.Lock()
+		tr.done = true
+		tr.mu.Unlock()
+	}
+}
+
+// evict untracks the ride, unless it was already untracked and tracked again.
+func (t *RideTracker) evict(tr *trackedRide) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.rides[tr.id] == tr {
+		delete(t.rides, tr.id)
+	}
+}
+
+// Close stops tracking all the rides.
+func (t *RideTracker) Close() {
+	t.mu.Lock()
+	t.closed = true
+	rides := t.rides
+	t.rides = make(map[int]*trackedRide)
+	t.mu.Unlock()
+
+	for _, tr := range rides {
+		tr.mu.Lock()
+		tr.done = true
+		tr.mu.Unlock()
+	}
+}
+
+// Snapshot returns a copy of the latest known state of the ride.
+func (t *RideTracker) Snapshot(rideID int) (*RideResult, bool) {
+	t.mu.Lock()
+	tr, ok := t.rides[rideID]
+	t.mu.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	r := tr.snapshot
+	return &r, true
+}
+
+// Rides returns the IDs of the tracked rides.
+func (t *RideTracker) Rides() []int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ids := make([]int, 0, len(t.rides))
+	for id := range t.rides {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Create creates the ride and starts tracking it, updating it
+// from the result of the creation as seen from SourceCreate.
+func (t *RideTracker) Create(ctx context.Context, r *Ride) (*RideResult, error) {
+	res, err := t.rs.Create(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	// The creation may not return the status of the new ride.
+	status := res.Info.Status
+	if status == "" {
+		status = RideStatusSearchingForDriver
+	}
+
+	if tr := t.track(res.ID); tr != nil {
+		t.update(tr, status, SourceCreate, res, func(s *RideResult) {
+			*s = *res
+			s.Info.Status = status
+			if s.Passenger.ID == 0 {
+				s.Passenger.ID = r.EmployeeID
+			}
+			if s.Info.ExternalID == "" {
+				s.Info.ExternalID = r.ExternalID
+			}
+		})
+	}
+
+	return res, nil
+}
+
+// Cancel cancels the ride, updating it, if tracked, to
+// RideStatusCancelled as seen from SourceCancel.
+func (t *RideTracker) Cancel(ctx context.Context, rideID int, reason int) (*Result, error) {
+	res, err := t.rs.Cancel(ctx, rideID, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	tr, ok := t.rides[rideID]
+	t.mu.Unlock()
+
+	if ok {
+		t.update(tr, RideStatusCancelled, SourceCancel, res, func(s *RideResult) {
+			s.Info.Status = RideStatusCancelled
+		})
+	}
+
+	return res, nil
+}
+
+// HandleRide implements the RideHandler interface, updating the ride from the webhook.
+func (t *RideTracker) HandleRide(ctx context.Context, r *WebhookRide) error {
+	t.mu.Lock()
+	tr, ok := t.rides[r.RideID]
+	t.mu.Unlock()
+
+	if !ok {
+		if !t.opts.AutoTrack {
+			return nil
+		}
+		if tr = t.track(r.RideID); tr == nil {
+			return nil
+		}
+	}
+
+	t.update(tr, r.Status, SourceWebhook, r, func(s *RideResult) {
+		s.Info.Status = r.Status
+		s.Info.DriverLocation = r.TaxiLocation
+		s.Info.ToOrigin = TravelInfo{Time: r.TimeToOrigin, TimeSec: r.TimeToOriginSec, DistanceKM: float64(r.DistanceToOriginKM)}
+		s.Info.ToDestiny = TravelInfo{Time: r.TimeToDestiny, TimeSec: r.TimeToDestinySec}
+		s.Info.RideValue = r.RideValue
+		s.Info.ExternalID = r.ExternalID
+		s.Origin.Location = r.OriginLocation
+		s.Destiny.Location = r.DestinyLocation
+		if r.EmployeeID != 0 {
+			s.Passenger.ID = r.EmployeeID
+		}
+	})
+
+	return nil
+}
+
+// check polls the ride if no webhook arrived within FallbackAfter,
+// scheduling the next check.
+func (t *RideTracker) check(tr *trackedRide) {
+	tr.mu.Lock()
+	done, last := tr.done, tr.lastWebhook
+	tr.mu.Unlock()
+
+	if done {
+		return
+	}
+
+	now := time.Now()
+	if wait := last.Add(t.opts.FallbackAfter).Sub(now); wait > 0 {
+		t.opts.Scheduler.schedule(now.Add(wait), func() { t.check(tr) })
+		return
+	}
+
+	r, err := t.rs.Read(context.Background(), Filter{"id": []string{strconv.Itoa(tr.id)}})
+	if err != nil {
+		if t.opts.OnError != nil {
+			t.opts.OnError(tr.id, err)
+		}
+	} else {
+		t.update(tr, r.Info.Status, SourcePoll, r, func(s *RideResult) {
+			*s = *r
+			s.ID = tr.id
+		})
+	}
+
+	t.opts.Scheduler.schedule(time.Now().Add(t.opts.PollInterval), func() { t.check(tr) })
+}
+
+// update applies the changes of a source, seen in the payload, to the snapshot
+// of the ride, unless they are older than it, emitting the change of status.
+func (t *RideTracker) update(tr *trackedRide, status RideStatus, source EventSource, payload interface{}, apply func(s *RideResult)) {
+	tr.mu.Lock()
+
+	if source == SourceWebhook {
+		tr.lastWebhook = time.Now()
+	}
+
+	// A finished ride only takes the statuses it may still move to, i.e. paid after completed.
+	prev := tr.snapshot.Info.Status
+	if (tr.done && !prev.CanTransitionTo(status)) || (prev != "" && status.Rank() < prev.Rank()) {
+		tr.mu.Unlock()
+		return
+	}
+
+	apply(&tr.snapshot)
+	if status.IsTerminal() {
+		tr.done = true
+		if t.opts.Retention > 0 {
+			t.opts.Scheduler.schedule(time.Now().Add(t.opts.Retention), func() { t.evict(tr) })
+		}
+	}
+
+	if status == prev || (t.opts.OnEvent == nil && t.opts.Events == nil) {
+		tr.mu.Unlock()
+		return
+	}
+
+	snapshot := tr.snapshot
+	e := &RideEvent{RideID: tr.id, Previous: prev, Status: status, Ride: &snapshot, At: time.Now(), Source: source}
+
+	// Taken before releasing the ride, so the events keep its order.
+	tr.emu.Lock()
+	tr.mu.Unlock()
+	defer tr.emu.Unlock()
+
+	if t.opts.Events != nil {
+		if err := t.record(e, payload); err != nil && t.opts.OnError != nil {
+			t.opts.OnError(tr.id, err)
+		}
+	}
+
+	if t.opts.OnEvent != nil {
+		t.opts.OnEvent(e)
+	}
+}
+
+// record appends the event to the Events store.
+func (t *RideTracker) record(e *RideEvent, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return t.opts.Events.Append(context.Background(), &RideEventRecord{
+		RideID:     e.RideID,
+		EmployeeID: e.Ride.Passenger.ID,
+		ExternalID: e.Ride.Info.ExternalID,
+		Status:     e.Status,
+		Source:     e.Source,
+		Payload:    b,
+		At:         e.At,
+	})
+}
@@ -0,0 +1,188 @@
+package wappa
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// trackerEvents records the events of a RideTracker.
+type trackerEvents struct {
+	mu     sync.Mutex
+	events []*RideEvent
+	done   chan struct{}
+}
+
+func (te *trackerEvents) onEvent(e *RideEvent) {
+	te.mu.Lock()
+	defer te.mu.Unlock()
+
+	te.events = append(te.events, e)
+	if e.Status.IsTerminal() && te.done != nil {
+		close(te.done)
+	}
+}
+
+func (te *trackerEvents) statuses() ([]RideStatus, []EventSource) {
+	te.mu.Lock()
+	defer te.mu.Unlock()
+
+	var statuses []RideStatus
+	var sources []EventSource
+	for _, e := range te.events {
+		statuses = append(statuses, e.Status)
+		sources = append(sources, e.Source)
+	}
+	return statuses, sources
+}
+
+func TestRideTrackerWebhook(t *testing.T) {
+	te := &trackerEvents{}
+	req := &statusRequester{steps: []interface{}{RideStatusSearchingForDriver}}
+	tr := NewRideTracker(&RideService{req}, &TrackerOptions{
+		FallbackAfter: time.Hour,
+		OnEvent:       te.onEvent,
+		Scheduler:     NewWatchScheduler(1),
+	})
+	defer tr.Close()
+
+	tr.Track(1)
+
+	for _, r := range []*WebhookRide{
+		{RideID: 1, Status: RideStatusDriverFound},
+		{RideID: 1, Status: RideStatusWaitingForDriver, TaxiLocation: Location{1, 2}},
+		{RideID: 1, Status: RideStatusDriverFound},
+		{RideID: 2, Status: RideStatusDriverFound},
+		{RideID: 1, Status: RideStatusWaitingForDriver, TaxiLocation: Location{3, 4}},
+	} {
+		if err := tr.HandleRide(context.Background(), r); err != nil {
+			t.Fatalf("got error calling HandleRide(): %s; want nil.", err.Error())
+		}
+	}
+
+	got, _ := te.statuses()
+	if want := []RideStatus{RideStatusDriverFound, RideStatusWaitingForDriver}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got statuses: %v; want %v.", got, want)
+	}
+
+	s, ok := tr.Snapshot(1)
+	if !ok || s.ID != 1 || s.Info.Status != RideStatusWaitingForDriver || s.Info.DriverLocation != (Location{3, 4}) {
+		t.Errorf("got snapshot: %+v, %t; want ride 1 waiting at the last location.", s, ok)
+	}
+
+	if _, ok := tr.Snapshot(2); ok {
+		t.Errorf("got snapshot of untracked ride; want none.")
+	}
+
+	if req.reads != 0 {
+		t.Errorf("got %d polls; want none while webhooks arrive.", req.reads)
+	}
+}
+
+func TestRideTrackerFallback(t *testing.T) {
+	te := &trackerEvents{done: make(chan struct{})}
+	req := &statusRequester{steps: []interface{}{RideStatusInProgress, RideStatusInProgress, RideStatusCompleted, RideStatusPaid}}
+	tr := NewRideTracker(&RideService{req}, &TrackerOptions{
+		FallbackAfter: 5 * time.Millisecond,
+		PollInterval:  time.Millisecond,
+		AutoTrack:     true,
+		OnEvent:       te.onEvent,
+		Scheduler:     NewWatchScheduler(1),
+	})
+	defer tr.Close()
+
+	tr.HandleRide(context.Background(), &WebhookRide{RideID: 1, Status: RideStatusDriverFound})
+
+	select {
+	case <-te.done:
+	case <-time.After(time.Second):
+		t.Fatalf("got ride not paid by polling; want it paid.")
+	}
+
+	statuses, sources := te.statuses()
+	if want := []RideStatus{RideStatusDriverFound, RideStatusInProgress, RideStatusCompleted, RideStatusPaid}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("got statuses: %v; want %v.", statuses, want)
+	}
+	if want := []EventSource{SourceWebhook, SourcePoll, SourcePoll, SourcePoll}; !reflect.DeepEqual(sources, want) {
+		t.Errorf("got sources: %v; want %v.", sources, want)
+	}
+
+	// Late deliveries of the paid ride are ignored.
+	tr.HandleRide(context.Background(), &WebhookRide{RideID: 1, Status: RideStatusCompleted})
+	if s, _ := tr.Snapshot(1); s.Info.Status != RideStatusPaid {
+		t.Errorf("got snapshot status: %s; want %s.", s.Info.Status, RideStatusPaid)
+	}
+
+	tr.Untrack(1)
+	if got := tr.Rides(); len(got) != 0 {
+		t.Errorf("got tracked rides: %v; want none.", got)
+	}
+}
+
+func TestRideTrackerRetention(t *testing.T) {
+	testCases := []struct {
+		name      string
+		retention time.Duration
+		wantRides int
+	}{
+		{"evicted", time.Millisecond, 1},
+		{"kept", -1, 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := NewRideTracker(&RideService{&statusRequester{steps: []interface{}{RideStatusSearchingForDriver}}}, &TrackerOptions{
+				FallbackAfter: time.Hour,
+				AutoTrack:     true,
+				Retention:     tc.retention,
+				Scheduler:     NewWatchScheduler(1),
+			})
+			defer tr.Close()
+
+			tr.HandleRide(context.Background(), &WebhookRide{RideID: 1, Status: RideStatusPaid})
+			tr.HandleRide(context.Background(), &WebhookRide{RideID: 2, Status: RideStatusInProgress})
+
+			time.Sleep(20 * time.Millisecond)
+
+			if got := tr.Rides(); len(got) != tc.wantRides {
+				t.Errorf("got tracked rides: %v; want %d.", got, tc.wantRides)
+			}
+			if _, ok := tr.Snapshot(2); !ok {
+				t.Errorf("got ride in progress untracked; want it tracked.")
+			}
+		})
+	}
+}
+
+func TestRideTrackerPaidAfterCompleted(t *testing.T) {
+	te := &trackerEvents{}
+	tr := NewRideTracker(&RideService{&statusRequester{}}, &TrackerOptions{
+		FallbackAfter: time.Hour,
+		OnEvent:       te.onEvent,
+		Scheduler:     NewWatchScheduler(1),
+	})
+	defer tr.Close()
+
+	tr.Track(1)
+
+	for _, r := range []*WebhookRide{
+		{RideID: 1, Status: RideStatusCompleted, RideValue: 10},
+		{RideID: 1, Status: RideStatusPaid, RideValue: 42},
+		{RideID: 1, Status: RideStatusCancelled, RideValue: 0},
+	} {
+		if err := tr.HandleRide(context.Background(), r); err != nil {
+			t.Fatalf("got error calling HandleRide(): %s; want nil.", err.Error())
+		}
+	}
+
+	got, _ := te.statuses()
+	if want := []RideStatus{RideStatusCompleted, RideStatusPaid}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got statuses: %v; want %v.", got, want)
+	}
+
+	if s, ok := tr.Snapshot(1); !ok || s.Info.Status != RideStatusPaid || s.Info.RideValue != 42 {
+		t.Errorf("got snapshot: %+v, %t; want ride 1 paid with the final value.", s, ok)
+	}
+}
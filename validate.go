@@ -0,0 +1,143 @@
+package wappa
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Length limits of the Ride text fields.
+const (
+	MaxOriginRefLength  = 255
+	MaxExternalIDLength = 64
+)
+
+// FieldError is an invalid field of a request.
+type FieldError struct {
+	// Name of the field in the Go struct.
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError is returned when a request is invalid before being sent
+// to the API. It matches ErrValidation with errors.Is.
+type ValidationError struct {
+	Fields []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Error()
+	}
+	return "wappa: invalid request: " + strings.Join(msgs, "; ")
+}
+
+// Is reports if target is ErrValidation.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// Field returns the error of the field, or nil if it is valid.
+func (e *ValidationError) Field(name string) *FieldError {
+	for _, f := range e.Fields {
+		if f.Field == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func (e *ValidationError) add(field, msg string) {
+	e.Fields = append(e.Fields, &FieldError{field, msg})
+}
+
+// err returns e if there are invalid fields, or nil.
+func (e *ValidationError) err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Validate checks the ride before it is created, returning
+// a *ValidationError with every invalid field, or nil.
+func (r *Ride) Validate() error {
+	e := &ValidationError{}
+	if r == nil {
+		e.add("Ride", "required")
+		return e
+	}
+
+	if r.EmployeeID <= 0 {
+		e.add("EmployeeID", "required")
+	}
+
+	validateCoordinates(e, "Origin", r.LatOrigin, r.LngOrigin)
+	validateCoordinates(e, "Destiny", r.LatDestiny, r.LngDestiny)
+	if r.LatOrigin == r.LatDestiny && r.LngOrigin == r.LngDestiny && (r.LatOrigin != 0 || r.LngOrigin != 0) {
+		e.add("LatDestiny", "destiny equals origin")
+	}
+
+	if utf8.RuneCountInString(r.OriginRef) > MaxOriginRefLength {
+		e.add("OriginRef", fmt.Sprintf("longer than %d characters", MaxOriginRefLength))
+	}
+	if utf8.RuneCountInString(r.ExternalID) > MaxExternalIDLength {
+		e.add("ExternalID", fmt.Sprintf("longer than %d characters", MaxExternalIDLength))
+	}
+
+	switch {
+	case r.PassengerPhoneAreaCode == "" && r.PassengerPhoneNumber == "":
+	case r.PassengerPhoneAreaCode == "":
+		e.add("PassengerPhoneAreaCode", "required with PassengerPhoneNumber")
+	case r.PassengerPhoneNumber == "":
+		e.add("PassengerPhoneNumber", "required with PassengerPhoneAreaCode")
+	default:
+		if !validDDD(r.PassengerPhoneAreaCode) {
+			e.add("PassengerPhoneAreaCode", "not a DDD of two digits from 11 to 99")
+		}
+		if !validPhone(r.PassengerPhoneNumber) {
+			e.add("PassengerPhoneNumber", "not a phone of 8 digits, or 9 digits starting by 9")
+		}
+	}
+
+	return e.err()
+}
+
+// validateCoordinates checks the latitude and longitude are set and within bounds.
+func validateCoordinates(e *ValidationError, name string, lat, lng float64) {
+	if lat == 0 && lng == 0 {
+		e.add("Lat"+name, "coordinates required")
+		return
+	}
+	if lat < -90 || lat > 90 {
+		e.add("Lat"+name, "latitude out of [-90, 90]")
+	}
+	if lng < -180 || lng > 180 {
+		e.add("Lng"+name, "longitude out of [-180, 180]")
+	}
+}
+
+// validDDD reports if s is a Brazilian area code. They range from 11 to 99,
+// without zeros.
+func validDDD(s string) bool {
+	return len(s) == 2 && s[0] >= '1' && s[0] <= '9' && s[1] >= '1' && s[1] <= '9'
+}
+
+// validPhone reports if s is a Brazilian phone number without the area code:
+// 8 digits for landlines, or 9 digits starting by 9 for mobiles.
+func validPhone(s string) bool {
+	if len(s) != 8 && !(len(s) == 9 && s[0] == '9') {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
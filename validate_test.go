@@ -0,0 +1,82 @@
+package wappa
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRideValidate(t *testing.T) {
+	ride := func(f func(r *Ride)) *Ride {
+		r := testRide
+		f(&r)
+		return &r
+	}
+
+	testCases := []struct {
+		name       string
+		ride       *Ride
+		wantFields []string
+	}{
+		{"valid", &testRide, nil},
+		{"valid phone", ride(func(r *Ride) { r.PassengerPhoneAreaCode, r.PassengerPhoneNumber = "11", "987654321" }), nil},
+		{"valid landline", ride(func(r *Ride) { r.PassengerPhoneAreaCode, r.PassengerPhoneNumber = "21", "32654321" }), nil},
+		{"nil", nil, []string{"Ride"}},
+		{"missing employee", ride(func(r *Ride) { r.EmployeeID = 0 }), []string{"EmployeeID"}},
+		{"zero coordinates", ride(func(r *Ride) { r.LatOrigin, r.LngOrigin = 0, 0 }), []string{"LatOrigin"}},
+		{"out of bounds", ride(func(r *Ride) { r.LatDestiny, r.LngOrigin = 91, -181 }), []string{"LngOrigin", "LatDestiny"}},
+		{"same origin and destiny", ride(func(r *Ride) { r.LatDestiny, r.LngDestiny = r.LatOrigin, r.LngOrigin }), []string{"LatDestiny"}},
+		{"long texts", ride(func(r *Ride) {
+			r.OriginRef = strings.Repeat("á", MaxOriginRefLength+1)
+			r.ExternalID = strings.Repeat("1", MaxExternalIDLength+1)
+		}), []string{"OriginRef", "ExternalID"}},
+		{"area code without number", ride(func(r *Ride) { r.PassengerPhoneAreaCode = "11" }), []string{"PassengerPhoneNumber"}},
+		{"number without area code", ride(func(r *Ride) { r.PassengerPhoneNumber = "987654321" }), []string{"PassengerPhoneAreaCode"}},
+		{"malformed phone", ride(func(r *Ride) { r.PassengerPhoneAreaCode, r.PassengerPhoneNumber = "01", "887654321" }), []string{"PassengerPhoneAreaCode", "PassengerPhoneNumber"}},
+		{"phone with letters", ride(func(r *Ride) { r.PassengerPhoneAreaCode, r.PassengerPhoneNumber = "11", "9876-4321" }), []string{"PassengerPhoneNumber"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.ride.Validate()
+			if tc.wantFields == nil {
+				if err != nil {
+					t.Fatalf("got error: %s; want nil.", err.Error())
+				}
+				return
+			}
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) || !errors.Is(err, ErrValidation) {
+				t.Fatalf("got error: %v; want a *ValidationError.", err)
+			}
+
+			var fields []string
+			for _, f := range verr.Fields {
+				fields = append(fields, f.Field)
+			}
+			if !reflect.DeepEqual(fields, tc.wantFields) {
+				t.Errorf("got invalid fields: %v; want %v.", fields, tc.wantFields)
+			}
+
+			if verr.Field(tc.wantFields[0]) == nil {
+				t.Errorf("got Field(%s): nil; want its error.", tc.wantFields[0])
+			}
+		})
+	}
+}
+
+func TestRideCreateValidation(t *testing.T) {
+	req := &testRequester{}
+
+	_, err := (&RideService{req}).Create(context.Background(), &Ride{})
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("got error: %v; want %v.", err, ErrValidation)
+	}
+
+	if req.method != "" {
+		t.Errorf("got request %s %s sent; want none.", req.method, req.path)
+	}
+}
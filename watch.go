@@ -0,0 +1,357 @@
+package wappa
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Default polling intervals of RideService.Watch.
+const (
+	DefaultWatchInterval   = 10 * time.Second
+	DefaultWatchMinBackoff = time.Second
+	DefaultWatchMaxBackoff = time.Minute
+)
+
+// DefaultWatchIntervals are the polling intervals of RideService.Watch by status.
+// Rides are polled faster while the driver is on the way, and slower on ride.
+var DefaultWatchIntervals = map[RideStatus]time.Duration{
+	RideStatusSearchingForDriver: 5 * time.Second,
+	RideStatusDriverFound:        5 * time.Second,
+	RideStatusWaitingForDriver:   3 * time.Second,
+	RideStatusInProgress:         30 * time.Second,
+}
+
+// DefaultWatchScheduler is the WatchScheduler used when WatchOptions has none.
+var DefaultWatchScheduler = NewWatchScheduler(16)
+
+// EventSource is where a RideEvent was seen.
+type EventSource int
+
+// Sources of events.
+const (
+	// The ride was read from the API.
+	SourcePoll EventSource = iota
+	// The ride was delivered by the webhook.
+	SourceWebhook
+	// The ride was created through RideTracker.Create.
+	SourceCreate
+	// The ride was cancelled through RideTracker.Cancel.
+	SourceCancel
+)
+
+// RideEvent is a change of status of a watched ride.
+type RideEvent struct {
+	RideID int
+	// Status of the ride before the change, empty on the first event.
+	Previous RideStatus
+	Status   RideStatus
+	// The ride as known after the change.
+	Ride *RideResult
+	// When the change was seen.
+	At     time.Time
+	Source EventSource
+}
+
+// WatchOptions configures RideService.Watch. The zero value is valid.
+type WatchOptions struct {
+	// Polling intervals by status. DefaultWatchIntervals is used if nil.
+	Intervals map[RideStatus]time.Duration
+	// Polling interval of the statuses missing from Intervals.
+	// DefaultWatchInterval is used if <= 0.
+	Interval time.Duration
+	// Backoff after the first failed poll, doubling on each consecutive
+	// failure up to MaxBackoff. The defaults are used if <= 0.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// OnEvent, if set, receives the events instead of the channel of the watch.
+	OnEvent func(e *RideEvent)
+	// Scheduler polling the ride. DefaultWatchScheduler is used if nil.
+	Scheduler *WatchScheduler
+}
+
+func (o *WatchOptions) interval(s RideStatus) time.Duration {
+	intervals := o.Intervals
+	if intervals == nil {
+		intervals = DefaultWatchIntervals
+	}
+	if d, ok := intervals[s]; ok && d > 0 {
+		return d
+	}
+	if o.Interval > 0 {
+		return o.Interval
+	}
+	return DefaultWatchInterval
+}
+
+func (o *WatchOptions) backoff(failures int) time.Duration {
+	p := &RetryPolicy{MinBackoff: o.MinBackoff, MaxBackoff: o.MaxBackoff}
+	if p.MinBackoff <= 0 {
+		p.MinBackoff = DefaultWatchMinBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = DefaultWatchMaxBackoff
+	}
+	return p.backoff(failures)
+}
+
+// RideWatch is a ride being polled by RideService.Watch.
+type RideWatch struct {
+	// C receives the changes of status of the ride. It is closed when the
+	// watch stops, after the ride reaches a terminal status, the context is
+	// done, the watch is stopped or the API fails permanently. See Err.
+	C <-chan *RideEvent
+
+	rs     *RideService
+	ctx    context.Context
+	cancel context.CancelFunc
+	id     int
+	opts   WatchOptions
+
+	// Only used by the polls, which never overlap.
+	last     RideStatus
+	failures int
+
+	mu      sync.Mutex
+	stopped bool
+	err     error
+
+	// Held while sending, so the channel isn't closed meanwhile.
+	smu    sync.Mutex
+	c      chan *RideEvent
+	closed bool
+}
+
+// Watch polls the status of the ride until it reaches a terminal status, emitting
+// only its changes. Transient failures of the API are retried with backoff, while
+// the others stop the watch. The watches share the polling goroutines of their
+// scheduler, and a slow consumer of the events holds one of them.
+//
+// opts may be nil to use the defaults.
+func (rs *RideService) Watch(ctx context.Context, rideID int, opts *WatchOptions) *RideWatch {
+	w := &RideWatch{rs: rs, id: rideID, c: make(chan *RideEvent, 1)}
+	if opts != nil {
+		w.opts = *opts
+	}
+	if w.opts.Scheduler == nil {
+		w.opts.Scheduler = DefaultWatchScheduler
+	}
+	w.C = w.c
+	w.ctx, w.cancel = context.WithCancel(ctx)
+
+	go func() {
+		<-w.ctx.Done()
+		w.stop(ctx.Err())
+	}()
+
+	w.opts.Scheduler.schedule(time.Now(), w.poll)
+
+	return w
+}
+
+// Stop stops the watch. It is safe to call more than once.
+func (w *RideWatch) Stop() {
+	w.cancel()
+}
+
+// Err returns why the watch stopped: nil if the ride reached a terminal status
+// or Stop was called, the context error, or the error of the API.
+func (w *RideWatch) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.err
+}
+
+// poll reads the ride and schedules the next poll, unless the watch is over.
+func (w *RideWatch) poll() {
+	if w.ctx.Err() != nil {
+		return
+	}
+
+	r, err := w.rs.Read(w.ctx, Filter{"id": []string{strconv.Itoa(w.id)}})
+	if err != nil {
+		if w.ctx.Err() != nil {
+			return
+		}
+		if !transient(err) {
+			w.stop(err)
+			w.cancel()
+			return
+		}
+		w.failures++
+		w.opts.Scheduler.schedule(time.Now().Add(w.opts.backoff(w.failures)), w.poll)
+		return
+	}
+	w.failures = 0
+
+	if st := r.Info.Status; st != w.last {
+		w.emit(&RideEvent{RideID: w.id, Previous: w.last, Status: st, Ride: r, At: time.Now()})
+		w.last = st
+	}
+
+	if w.last.IsTerminal() {
+		w.cancel()
+		return
+	}
+
+	w.opts.Scheduler.schedule(time.Now().Add(w.opts.interval(w.last)), w.poll)
+}
+
+func (w *RideWatch) emit(e *RideEvent) {
+	if w.opts.OnEvent != nil {
+		w.opts.OnEvent(e)
+		return
+	}
+
+	// The send doesn't hold mu, so Err can be called while it blocks.
+	w.smu.Lock()
+	defer w.smu.Unlock()
+
+	if w.closed {
+		return
+	}
+
+	select {
+	case w.c <- e:
+	case <-w.ctx.Done():
+	}
+}
+
+// stop closes the channel of the watch, recording the first error. It waits
+// for the send in progress, if any, which ends as the context is done.
+func (w *RideWatch) stop(err error) {
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return
+	}
+	w.stopped = true
+	w.err = err
+	w.mu.Unlock()
+
+	w.smu.Lock()
+	defer w.smu.Unlock()
+
+	w.closed = true
+	close(w.c)
+}
+
+// transient reports if the error may succeed on a later attempt.
+func transient(err error) bool {
+	var apiErr *ApiError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	return errors.Is(apiErr, ErrServer) || errors.Is(apiErr, ErrRateLimited)
+}
+
+// WatchScheduler runs the polls of many watches from a single timer,
+// in up to a number of concurrent goroutines. It is safe for concurrent use.
+type WatchScheduler struct {
+	sem chan struct{}
+
+	mu      sync.Mutex
+	jobs    watchJobs
+	running bool
+	wake    chan struct{}
+}
+
+// NewWatchScheduler returns a WatchScheduler running up to concurrency polls at once.
+func NewWatchScheduler(concurrency int) *WatchScheduler {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &WatchScheduler{
+		sem:  make(chan struct{}, concurrency),
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// schedule runs f at the given time.
+func (s *WatchScheduler) schedule(at time.Time, f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	heap.Push(&s.jobs, &watchJob{at, f})
+
+	// The timer goroutine only runs while there are jobs.
+	if !s.running {
+		s.running = true
+		go s.run()
+		return
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *WatchScheduler) run() {
+	t := time.NewTimer(time.Hour)
+	defer t.Stop()
+
+	for {
+		s.mu.Lock()
+		if s.jobs.Len() == 0 {
+			s.running = false
+			s.mu.Unlock()
+			return
+		}
+
+		next := s.jobs[0]
+		d := time.Until(next.at)
+		if d <= 0 {
+			heap.Pop(&s.jobs)
+			s.mu.Unlock()
+
+			s.sem <- struct{}{}
+			go func() {
+				defer func() { <-s.sem }()
+				next.f()
+			}()
+			continue
+		}
+		s.mu.Unlock()
+
+		if !t.Stop() {
+			select {
+			case <-t.C:
+			default:
+			}
+		}
+		t.Reset(d)
+
+		// Woken up earlier by jobs scheduled in the meantime.
+		select {
+		case <-t.C:
+		case <-s.wake:
+		}
+	}
+}
+
+type watchJob struct {
+	at time.Time
+	f  func()
+}
+
+// watchJobs is a min-heap of jobs by time.
+type watchJobs []*watchJob
+
+func (h watchJobs) Len() int            { return len(h) }
+func (h watchJobs) Less(i, j int) bool  { return h[i].at.Before(h[j].at) }
+func (h watchJobs) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *watchJobs) Push(x interface{}) { *h = append(*h, x.(*watchJob)) }
+
+func (h *watchJobs) Pop() interface{} {
+	old := *h
+	n := len(old)
+	j := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return j
+}
@@ -0,0 +1,215 @@
+package wappa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// statusRequester answers the ride status reads with a sequence of statuses
+// or errors, repeating the last one.
+type statusRequester struct {
+	mu    sync.Mutex
+	reads int
+	steps []interface{}
+}
+
+func (s *statusRequester) Request(ctx context.Context, method string, path endpoint, body, output interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	step := s.steps[len(s.steps)-1]
+	if s.reads < len(s.steps) {
+		step = s.steps[s.reads]
+	}
+	s.reads++
+
+	if err, ok := step.(error); ok {
+		return err
+	}
+	output.(*RideResult).Info.Status = step.(RideStatus)
+	return nil
+}
+
+var testWatchOptions = &WatchOptions{
+	Interval:   time.Millisecond,
+	Intervals:  map[RideStatus]time.Duration{},
+	MinBackoff: time.Millisecond,
+	MaxBackoff: 5 * time.Millisecond,
+	Scheduler:  NewWatchScheduler(4),
+}
+
+func TestRideWatch(t *testing.T) {
+	serverErr := &ApiError{StatusCode: http.StatusBadGateway, Err: ErrServer}
+	authErr := &ApiError{StatusCode: http.StatusUnauthorized, Err: ErrAuth}
+
+	testCases := []struct {
+		name    string
+		steps   []interface{}
+		want    []RideStatus
+		wantErr error
+	}{
+		{
+			"terminal",
+			[]interface{}{RideStatusSearchingForDriver, RideStatusSearchingForDriver, RideStatusDriverFound, RideStatusInProgress, RideStatusCompleted, RideStatusPaid, RideStatusCancelled},
+			[]RideStatus{RideStatusSearchingForDriver, RideStatusDriverFound, RideStatusInProgress, RideStatusCompleted, RideStatusPaid},
+			nil,
+		},
+		{
+			"transient error",
+			[]interface{}{RideStatusDriverFound, serverErr, serverErr, RideStatusDriverFound, RideStatusCancelled},
+			[]RideStatus{RideStatusDriverFound, RideStatusCancelled},
+			nil,
+		},
+		{
+			"permanent error",
+			[]interface{}{RideStatusWaitingForDriver, authErr},
+			[]RideStatus{RideStatusWaitingForDriver},
+			authErr,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rs := &RideService{&statusRequester{steps: tc.steps}}
+			w := rs.Watch(context.Background(), 7, testWatchOptions)
+
+			var got []RideStatus
+			var prev RideStatus
+			for e := range w.C {
+				if e.RideID != 7 || e.Previous != prev {
+					t.Errorf("got event %+v; want ride 7 changed from '%s'.", e, prev)
+				}
+				prev = e.Status
+				got = append(got, e.Status)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got statuses: %v; want %v.", got, tc.want)
+			}
+
+			if err := w.Err(); err != tc.wantErr {
+				t.Errorf("got Err(): %v; want %v.", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestRideWatchCancel(t *testing.T) {
+	rs := &RideService{&statusRequester{steps: []interface{}{RideStatusInProgress}}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := rs.Watch(ctx, 1, testWatchOptions)
+
+	if e := <-w.C; e.Status != RideStatusInProgress {
+		t.Fatalf("got status: %s; want %s.", e.Status, RideStatusInProgress)
+	}
+	cancel()
+
+	select {
+	case _, ok := <-w.C:
+		if ok {
+			t.Errorf("got event after cancel; want channel closed.")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("got channel open after cancel; want it closed.")
+	}
+
+	if err := w.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("got Err(): %v; want %v.", err, context.Canceled)
+	}
+
+	w = rs.Watch(context.Background(), 1, testWatchOptions)
+	w.Stop()
+	for range w.C {
+	}
+	if err := w.Err(); err != nil {
+		t.Errorf("got Err() after Stop(): %v; want nil.", err)
+	}
+}
+
+func TestRideWatchOnEvent(t *testing.T) {
+	steps := []interface{}{RideStatusSearchingForDriver, RideStatusDriverNotFound}
+
+	var mu sync.Mutex
+	var got []RideStatus
+	opts := *testWatchOptions
+	opts.OnEvent = func(e *RideEvent) {
+		mu.Lock()
+		got = append(got, e.Status)
+		mu.Unlock()
+	}
+
+	w := (&RideService{&statusRequester{steps: steps}}).Watch(context.Background(), 1, &opts)
+	for range w.C {
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []RideStatus{RideStatusSearchingForDriver, RideStatusDriverNotFound}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got statuses: %v; want %v.", got, want)
+	}
+}
+
+func TestWatchScheduler(t *testing.T) {
+	s := NewWatchScheduler(2)
+	now := time.Now()
+
+	var mu sync.Mutex
+	var got []int
+	var wg sync.WaitGroup
+	for i, d := range []time.Duration{30, 10, 20} {
+		i := i
+		wg.Add(1)
+		s.schedule(now.Add(d*time.Millisecond), func() {
+			defer wg.Done()
+			mu.Lock()
+			got = append(got, i)
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+
+	if want := []int{1, 2, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got jobs run in order: %v; want %v.", got, want)
+	}
+}
+
+func TestRideWatchErrWhileSending(t *testing.T) {
+	req := &statusRequester{steps: []interface{}{RideStatusSearchingForDriver, RideStatusDriverFound, RideStatusInProgress}}
+	w := (&RideService{req}).Watch(context.Background(), 7, testWatchOptions)
+	defer w.Stop()
+
+	// Waits for the second event to block, the first filling the channel.
+	for {
+		req.mu.Lock()
+		reads := req.reads
+		req.mu.Unlock()
+		if reads >= 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan error)
+	go func() { done <- w.Err() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("got error: %s; want nil.", err.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("got Err() blocked by the pending event; want it to return.")
+	}
+
+	for _, want := range []RideStatus{RideStatusSearchingForDriver, RideStatusDriverFound} {
+		if e := <-w.C; e.Status != want {
+			t.Errorf("got status: %s; want %s.", e.Status, want)
+		}
+	}
+}
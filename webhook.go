@@ -2,7 +2,9 @@ package wappa
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"strings"
 )
 
 const webhookEndpoint endpoint = `webhook`
@@ -16,6 +18,11 @@ type Webhook struct {
 	Active   bool   `json:"active,omitempty"`
 }
 
+// Path returns the URL path the webhook handler should be mounted at.
+func (w *Webhook) Path() string {
+	return "/" + strings.TrimPrefix(w.Endpoint, "/")
+}
+
 // WebhookResult is the API response payload.
 type WebhookResult struct {
 	Result
@@ -25,21 +32,21 @@ type WebhookResult struct {
 
 // WebhookRide is the payload sent by the Webhook.
 type WebhookRide struct {
-	Code               int      `json:"code"`
-	RideID             int      `json:"rideId"`
-	CompanyID          int      `json:"companyId"`
-	EmployeeID         int      `json:"employeeId"`
-	Status             string   `json:"status"`
-	TaxiLocation       Location `json:"taxiLocation"`
-	OriginLocation     Location `json:"originLocation"`
-	DestinyLocation    Location `json:"destinyLocation"`
-	TimeToOriginSec    int      `json:"timeToOriginSec"`
-	TimeToOrigin       Duration `json:"timeToOrigin"`
-	DistanceToOriginKM int      `json:"destanceToOriginKm"`
-	TimeToDestinySec   int      `json:"timeToDestinySec"`
-	TimeToDestiny      Duration `json:"timeToDestiny"`
-	RideValue          float64  `json:"rideValue"`
-	ExternalID         string   `json:"externalId"`
+	Code               int        `json:"code"`
+	RideID             int        `json:"rideId"`
+	CompanyID          int        `json:"companyId"`
+	EmployeeID         int        `json:"employeeId"`
+	Status             RideStatus `json:"status"`
+	TaxiLocation       Location   `json:"taxiLocation"`
+	OriginLocation     Location   `json:"originLocation"`
+	DestinyLocation    Location   `json:"destinyLocation"`
+	TimeToOriginSec    int        `json:"timeToOriginSec"`
+	TimeToOrigin       Duration   `json:"timeToOrigin"`
+	DistanceToOriginKM int        `json:"destanceToOriginKm"`
+	TimeToDestinySec   int        `json:"timeToDestinySec"`
+	TimeToDestiny      Duration   `json:"timeToDestiny"`
+	RideValue          float64    `json:"rideValue"`
+	ExternalID         string     `json:"externalId"`
 }
 
 // WebhookService is responsible for handling
@@ -106,3 +113,144 @@ func (ws *WebhookService) Deactivate(ctx context.Context) (*Result, error) {
 
 	return res, nil
 }
+
+// WebhookAction is a change made by WebhookService.Ensure.
+type WebhookAction string
+
+// Actions of WebhookService.Ensure.
+const (
+	WebhookCreated   WebhookAction = "create"
+	WebhookUpdated   WebhookAction = "update"
+	WebhookActivated WebhookAction = "activate"
+)
+
+// WebhookChange is a field of the webhook changed by WebhookService.Ensure.
+// The AuthKey values are masked.
+type WebhookChange struct {
+	Field string
+	From  string
+	To    string
+}
+
+// WebhookDiff describes the changes WebhookService.Ensure made, or
+// would make in the dry-run mode, to match the desired webhook.
+type WebhookDiff struct {
+	// The webhook in the API before the changes, nil if there was none.
+	Current *Webhook
+	Desired *Webhook
+	Actions []WebhookAction
+	Changes []WebhookChange
+	// DryRun is true if the actions were not applied.
+	DryRun bool
+}
+
+// Changed reports if the webhook in the API didn't match the desired one.
+func (d *WebhookDiff) Changed() bool {
+	return len(d.Actions) > 0
+}
+
+// String implements the fmt.Stringer interface.
+func (d *WebhookDiff) String() string {
+	if !d.Changed() {
+		return "webhook up to date"
+	}
+
+	var b strings.Builder
+	for i, a := range d.Actions {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(string(a))
+	}
+	for _, c := range d.Changes {
+		fmt.Fprintf(&b, "; %s: %q -> %q", c.Field, c.From, c.To)
+	}
+	if d.DryRun {
+		b.WriteString(" (dry run)")
+	}
+
+	return b.String()
+}
+
+// EnsureOptions configures WebhookService.Ensure.
+type EnsureOptions struct {
+	// DryRun only computes the changes, without applying them.
+	DryRun bool
+}
+
+// Ensure makes the webhook in the API match the desired URL, Endpoint and
+// AuthKey, and be active, creating or updating it as needed. It is a no-op
+// if the webhook already matches, so it is safe to call at every startup.
+//
+// opts may be nil. The returned diff describes the changes made.
+func (ws *WebhookService) Ensure(ctx context.Context, desired *Webhook, opts *EnsureOptions) (*WebhookDiff, error) {
+	wr, err := ws.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &WebhookDiff{Desired: desired, DryRun: opts != nil && opts.DryRun}
+	if len(wr.Listeners) > 0 {
+		d.Current = wr.Listeners[0]
+	}
+
+	cur := d.Current
+	if cur == nil {
+		cur = &Webhook{}
+	}
+	d.Changes = webhookChanges(cur, desired)
+
+	switch {
+	case d.Current == nil:
+		d.Actions = append(d.Actions, WebhookCreated)
+	case len(d.Changes) > 0:
+		d.Actions = append(d.Actions, WebhookUpdated)
+	}
+	// Webhooks are activated after being created, not to depend on their default status.
+	if d.Current == nil || !d.Current.Active {
+		d.Actions = append(d.Actions, WebhookActivated)
+	}
+
+	if d.DryRun {
+		return d, nil
+	}
+
+	for _, a := range d.Actions {
+		switch a {
+		case WebhookCreated:
+			_, err = ws.Create(ctx, desired)
+		case WebhookUpdated:
+			_, err = ws.Update(ctx, desired)
+		case WebhookActivated:
+			_, err = ws.Activate(ctx)
+		}
+		if err != nil {
+			return d, fmt.Errorf("wappa: webhook %s: %w", a, err)
+		}
+	}
+
+	return d, nil
+}
+
+// webhookChanges returns the fields of the desired webhook differing from the current one.
+func webhookChanges(cur, desired *Webhook) []WebhookChange {
+	var changes []WebhookChange
+	if cur.URL != desired.URL {
+		changes = append(changes, WebhookChange{"URL", cur.URL, desired.URL})
+	}
+	if cur.Endpoint != desired.Endpoint {
+		changes = append(changes, WebhookChange{"Endpoint", cur.Endpoint, desired.Endpoint})
+	}
+	if cur.AuthKey != desired.AuthKey {
+		changes = append(changes, WebhookChange{"AuthKey", maskKey(cur.AuthKey), maskKey(desired.AuthKey)})
+	}
+	return changes
+}
+
+// maskKey hides all but the last characters of the key.
+func maskKey(key string) string {
+	if len(key) < 12 {
+		return strings.Repeat("*", len(key))
+	}
+	return strings.Repeat("*", len(key)-4) + key[len(key)-4:]
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -129,3 +131,133 @@ func TestWebhookError(t *testing.T) {
 		t.Run(tc.name, testError(tc))
 	}
 }
+
+// webhookAPI is a fake of the webhook endpoints, recording the changes requested.
+type webhookAPI struct {
+	current *Webhook
+	calls   []endpoint
+}
+
+func (a *webhookAPI) Request(ctx context.Context, method string, path endpoint, body, output interface{}) error {
+	if path != webhookEndpoint || method != http.MethodGet {
+		a.calls = append(a.calls, path)
+	}
+
+	switch path {
+	case webhookEndpoint:
+		if method == http.MethodGet {
+			if a.current != nil {
+				output.(*WebhookResult).Listeners = []*Webhook{a.current}
+			}
+			return nil
+		}
+		wh := *body.(*Webhook)
+		a.current = &wh
+	case webhookEndpoint.Action(update):
+		wh := *body.(*Webhook)
+		a.current = &wh
+	case webhookEndpoint.Action(activate):
+		a.current.Active = true
+	}
+
+	return nil
+}
+
+func TestWebhookEnsure(t *testing.T) {
+	desired := &Webhook{URL: "https://example.com", Endpoint: "hooks/wappa", AuthKey: "auth-key-secret"}
+
+	testCases := []struct {
+		name        string
+		current     *Webhook
+		dryRun      bool
+		wantActions []WebhookAction
+		wantFields  []string
+		wantCalls   []endpoint
+	}{
+		{
+			"missing",
+			nil,
+			false,
+			[]WebhookAction{WebhookCreated, WebhookActivated},
+			[]string{"URL", "Endpoint", "AuthKey"},
+			[]endpoint{webhookEndpoint, webhookEndpoint.Action(activate)},
+		},
+		{
+			"outdated and inactive",
+			&Webhook{URL: "https://example.com", Endpoint: "old", AuthKey: "auth-key-secret"},
+			false,
+			[]WebhookAction{WebhookUpdated, WebhookActivated},
+			[]string{"Endpoint"},
+			[]endpoint{webhookEndpoint.Action(update), webhookEndpoint.Action(activate)},
+		},
+		{
+			"inactive",
+			&Webhook{URL: "https://example.com", Endpoint: "hooks/wappa", AuthKey: "auth-key-secret"},
+			false,
+			[]WebhookAction{WebhookActivated},
+			nil,
+			[]endpoint{webhookEndpoint.Action(activate)},
+		},
+		{
+			"up to date",
+			&Webhook{URL: "https://example.com", Endpoint: "hooks/wappa", AuthKey: "auth-key-secret", Active: true},
+			false,
+			nil,
+			nil,
+			nil,
+		},
+		{
+			"dry run",
+			&Webhook{URL: "https://old.example.com", Endpoint: "hooks/wappa", AuthKey: "other-key", Active: true},
+			true,
+			[]WebhookAction{WebhookUpdated},
+			[]string{"URL", "AuthKey"},
+			nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api := &webhookAPI{current: tc.current}
+
+			d, err := (&WebhookService{api}).Ensure(context.Background(), desired, &EnsureOptions{DryRun: tc.dryRun})
+			if err != nil {
+				t.Fatalf("got error calling Ensure(): %s; want nil.", err.Error())
+			}
+
+			if !reflect.DeepEqual(d.Actions, tc.wantActions) {
+				t.Errorf("got actions: %v; want %v.", d.Actions, tc.wantActions)
+			}
+
+			var fields []string
+			for _, c := range d.Changes {
+				fields = append(fields, c.Field)
+				if c.Field == "AuthKey" && strings.Contains(c.To, desired.AuthKey) {
+					t.Errorf("got AuthKey unmasked in the diff: %s.", d)
+				}
+			}
+			if !reflect.DeepEqual(fields, tc.wantFields) {
+				t.Errorf("got changed fields: %v; want %v.", fields, tc.wantFields)
+			}
+
+			if !reflect.DeepEqual(api.calls, tc.wantCalls) {
+				t.Errorf("got calls: %v; want %v.", api.calls, tc.wantCalls)
+			}
+
+			if d.Current != tc.current {
+				t.Errorf("got current webhook: %+v; want %+v.", d.Current, tc.current)
+			}
+
+			if want := (Webhook{desired.URL, desired.Endpoint, desired.AuthKey, true}); !tc.dryRun && (api.current == nil || *api.current != want) {
+				t.Errorf("got webhook in the API: %+v; want %+v.", api.current, want)
+			}
+		})
+	}
+}
+
+func TestWebhookEnsureError(t *testing.T) {
+	_, err := (&WebhookService{&testRequester{err: ErrServer}}).Ensure(context.Background(), &Webhook{}, nil)
+	if !errors.Is(err, ErrServer) {
+		t.Errorf("got error: %v; want %v.", err, ErrServer)
+	}
+}